@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/fatih/color"
@@ -58,7 +59,8 @@ func runAggregate(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return err
 		}
-		if !info.IsDir() && filepath.Ext(path) == ".json" && filepath.Base(path) != "aggregate.json" {
+		// Only single-run reports; skip aggregate and spec summary files
+		if !info.IsDir() && filepath.Ext(path) == ".json" && strings.HasPrefix(filepath.Base(path), "report_") {
 			reportFiles = append(reportFiles, path)
 		}
 		return nil
@@ -87,6 +89,15 @@ func runAggregate(cmd *cobra.Command, args []string) error {
 			continue
 		}
 
+		if r.Unit == "" {
+			// Reports written before metric-aware modes always measured wall time
+			r.Metric, r.Unit = "duration", "ms"
+		}
+		if !r.Comparison.HasGain() {
+			fmt.Printf("   ⚠ Skipping %s: no gain to aggregate\n", path)
+			continue
+		}
+
 		reports = append(reports, r)
 		fmt.Printf("   ✓ Loaded: %s (machine: %s, gain: %.2f%%)\n",
 			filepath.Base(path), r.Machine, r.Comparison.GainPercent)
@@ -119,7 +130,7 @@ func runAggregate(cmd *cobra.Command, args []string) error {
 	// Per-machine table
 	fmt.Println("\n" + bold.Sprint("Per-Machine Results:"))
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Machine", "Gain %", "Baseline (ms)", "Optimized (ms)", "Verdict", "Tag"})
+	table.SetHeader([]string{"Machine", "Gain %", "Baseline", "Optimized", "Verdict", "Tag"})
 	table.SetBorder(false)
 
 	for _, r := range reports {
@@ -130,8 +141,8 @@ func runAggregate(cmd *cobra.Command, args []string) error {
 		table.Append([]string{
 			r.Machine,
 			fmt.Sprintf("%.2f", r.Comparison.GainPercent),
-			fmt.Sprintf("%.2f", r.Baseline.Stats.Median),
-			fmt.Sprintf("%.2f", r.Optimized.Stats.Median),
+			fmt.Sprintf("%.2f %s", r.Baseline.Stats.Median, r.Unit),
+			fmt.Sprintf("%.2f %s", r.Optimized.Stats.Median, r.Unit),
 			verdict,
 			r.Tag,
 		})
@@ -142,10 +153,10 @@ func runAggregate(cmd *cobra.Command, args []string) error {
 	fmt.Println("\n📄 Generating aggregate reports...")
 
 	aggReport := report.AggregateReport{
-		Version:     "1.0",
-		GeneratedAt: time.Now().UTC(),
+		Version:      "1.0",
+		GeneratedAt:  time.Now().UTC(),
 		MachineCount: len(reports),
-		Reports:     reports,
+		Reports:      reports,
 		AggregateStats: report.AggregateStats{
 			MedianGain: aggStats.Median,
 			MeanGain:   aggStats.Mean,
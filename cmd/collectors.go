@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/processgain/internal/collect"
+	"github.com/processgain/internal/report"
+)
+
+// collectAuto enables eBPF when possible, /proc sampling otherwise, the perf
+// counters and pressure stall information.
+const collectAuto = "auto"
+
+// collectNone runs none of the built-in collectors.
+const collectNone = "none"
+
+// parseCollect splits a --collect list; "auto" yields nil, an explicitly
+// empty list is "none".
+func parseCollect(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return []string{collectNone}
+	}
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" && name != collectAuto {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parseCollectors parses repeated --collector name=command flags.
+func parseCollectors(values []string) ([]report.Collector, error) {
+	var collectors []report.Collector
+	for _, v := range values {
+		name, command, ok := strings.Cut(v, "=")
+		if !ok || name == "" || command == "" {
+			return nil, fmt.Errorf("invalid --collector %q (expected name=command)", v)
+		}
+		collectors = append(collectors, report.Collector{Name: name, Command: command})
+	}
+	return collectors, nil
+}
+
+// setupCollectors creates the collectors of a suite: the ones listed in
+// cfg.Collect (the auto set when none are listed, no built-in one for
+// "none") followed by the user-defined ones. Collectors that cannot run
+// here are skipped with a warning. cfg.Collect is set to the enabled list.
+func setupCollectors(cfg *report.Config) (collect.Set, error) {
+	green := color.New(color.FgGreen)
+	yellow := color.New(color.FgYellow)
+
+	for _, c := range cfg.Collectors {
+		if err := collect.RegisterCommand(c.Name, c.Command); err != nil {
+			return nil, err
+		}
+	}
+
+	names := cfg.Collect
+	auto := len(names) == 0
+	switch {
+	case auto:
+		names = []string{"ebpf", "proc", "perf", "psi"}
+	case contains(names, collectNone):
+		if len(names) > 1 {
+			return nil, fmt.Errorf("--collect %s cannot be combined with other collectors", collectNone)
+		}
+		names = nil
+	}
+	for _, c := range cfg.Collectors {
+		if !contains(names, c.Name) {
+			names = append(names, c.Name)
+		}
+	}
+
+	var set collect.Set
+	var enabled []string
+	fmt.Println()
+	for _, name := range names {
+		if name == "ebpf" && noEbpf {
+			yellow.Println("⚠ eBPF collection disabled (--no-ebpf)")
+			continue
+		}
+		// In auto mode /proc sampling only stands in for eBPF
+		if auto && name == "proc" && contains(enabled, "ebpf") {
+			continue
+		}
+		c, err := collect.New(name)
+		if err != nil {
+			set.Close()
+			return nil, err
+		}
+		if err := c.Probe(); err != nil {
+			yellow.Printf("⚠ %s collector unavailable: %v\n", name, err)
+			c.Close()
+			continue
+		}
+		green.Printf("✓ %s collector enabled (%s)\n", name, c.Info())
+		set = append(set, c)
+		enabled = append(enabled, name)
+	}
+	cfg.Collect = enabled
+	return set, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
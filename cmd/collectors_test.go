@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/processgain/internal/report"
+)
+
+func TestParseCollect(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{value: "auto", want: nil},
+		{value: "ebpf, perf", want: []string{"ebpf", "perf"}},
+		{value: "none", want: []string{collectNone}},
+		{value: "", want: []string{collectNone}},
+		{value: " ", want: []string{collectNone}},
+	}
+	for _, tt := range tests {
+		if got := parseCollect(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseCollect(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSetupCollectorsNone(t *testing.T) {
+	cfg := &report.Config{Collect: parseCollect("none")}
+	set, err := setupCollectors(cfg)
+	if err != nil {
+		t.Fatalf("setupCollectors() error = %v", err)
+	}
+	if len(set) != 0 || len(cfg.Collect) != 0 {
+		t.Errorf("setupCollectors() enabled %q, want no collector", cfg.Collect)
+	}
+
+	cfg = &report.Config{Collect: parseCollect("none,perf")}
+	if _, err := setupCollectors(cfg); err == nil {
+		t.Error("setupCollectors() of none with perf succeeded, want an error")
+	}
+}
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/processgain/internal/executor"
+	"github.com/processgain/internal/report"
+	"github.com/processgain/internal/spec"
+	"github.com/spf13/pflag"
+)
+
+// Scenario names reserved for the baseline and the --optimized candidate.
+const (
+	baselineName  = "baseline"
+	optimizedName = "optimized"
+)
+
+// flagConfig builds a suite configuration from the command-line flags alone.
+func flagConfig() (report.Config, error) {
+	extra, err := parseCandidates(candidateFlags)
+	if err != nil {
+		return report.Config{}, err
+	}
+	collectors, err := parseCollectors(collectorFlags)
+	if err != nil {
+		return report.Config{}, err
+	}
+	cfg := report.Config{
+		BaselineScript:   baselineScript,
+		OptimizedScript:  optimizedScript,
+		Candidates:       extra,
+		Order:            order,
+		Mode:             mode,
+		WarmupRuns:       warmupRuns,
+		MeasuredRuns:     runs,
+		Alternate:        alternate,
+		CooldownMs:       cooldownMs,
+		Timeout:          timeout,
+		EnvFile:          envFile,
+		VerdictThreshold: verdictThreshold,
+		Confidence:       confidence,
+		Resamples:        resamples,
+		Seed:             seed,
+		Alpha:            alpha,
+		MinEffect:        minEffect,
+		Adaptive:         adaptive,
+		MinRuns:          minRuns,
+		MaxRuns:          maxRuns,
+		BatchSize:        batchSize,
+		TargetCIWidth:    targetCIWidth,
+		BudgetSec:        budget.Seconds(),
+		Collect:          parseCollect(collectList),
+		Collectors:       collectors,
+		CgroupMode:       cgroupMode,
+		CPUMax:           cpuMax,
+		CPUSetCPUs:       cpusetCPUs,
+		MemoryMax:        memoryMax,
+		CPUs:             cpuList,
+		SameCores:        sameCores,
+		Nice:             niceValue,
+		IONice:           ioNice,
+		Artifacts:        artifacts,
+		ArtifactMaxSize:  artifactMaxSize,
+		ArtifactGzip:     artifactGzip,
+		Verify:           verifySource,
+		VerifyCommand:    verifyCommand,
+		VerifyChecksum:   verifyChecksum,
+		Hooks:            hooks,
+	}
+	if tag != "" {
+		cfg.Tags = []string{tag}
+	}
+	return cfg, nil
+}
+
+// parseCandidates parses repeated --candidate name=path flags.
+func parseCandidates(values []string) ([]report.Candidate, error) {
+	var candidates []report.Candidate
+	for _, v := range values {
+		name, script, ok := strings.Cut(v, "=")
+		if !ok || name == "" || script == "" {
+			return nil, fmt.Errorf("invalid --candidate %q (expected name=path)", v)
+		}
+		candidates = append(candidates, report.Candidate{Name: name, Script: script})
+	}
+	return candidates, nil
+}
+
+// candidatesOf lists the scenarios compared against the baseline: the
+// --optimized script, if any, followed by the named candidates.
+func candidatesOf(cfg report.Config) []report.Candidate {
+	var candidates []report.Candidate
+	if cfg.OptimizedScript != "" {
+		candidates = append(candidates, report.Candidate{Name: optimizedName, Script: cfg.OptimizedScript})
+	}
+	return append(candidates, cfg.Candidates...)
+}
+
+// schedulingOf resolves the CPU pinning and priority settings of a suite.
+// With --same-cores every scenario is pinned to the whole --cpus set, or to
+// the CPUs CoreCut may use without one, recorded in cfg.CPUs as applied.
+func schedulingOf(cfg *report.Config) (executor.Scheduling, error) {
+	var s executor.Scheduling
+	var err error
+	if cfg.CPUs != "" {
+		if s.CPUs, err = executor.ParseCPUList(cfg.CPUs); err != nil {
+			return s, err
+		}
+	}
+	if cfg.SameCores && len(s.CPUs) == 0 {
+		if s.CPUs, err = executor.AllowedCPUs(); err != nil {
+			return s, err
+		}
+	}
+	if len(s.CPUs) > 0 {
+		cfg.CPUs = executor.FormatCPUList(s.CPUs)
+	}
+	s.Nice = cfg.Nice
+	if cfg.IONice != "" {
+		if s.IOClass, s.IOLevel, err = executor.ParseIONice(cfg.IONice); err != nil {
+			return s, err
+		}
+	}
+	return s, s.Check()
+}
+
+// suiteConfig layers a spec suite on top of the flag defaults. A flag given
+// explicitly on the command line always wins over the spec file.
+func suiteConfig(s spec.Suite, specPath string, flags *pflag.FlagSet) (report.Config, error) {
+	cfg, err := flagConfig()
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Suite = s.Name
+	cfg.SpecFile = specPath
+	cfg.Env = s.Env
+
+	setString := func(flag string, dst *string, v string) {
+		if v != "" && !flags.Changed(flag) {
+			*dst = v
+		}
+	}
+	setInt := func(flag string, dst *int, v *int) {
+		if v != nil && !flags.Changed(flag) {
+			*dst = *v
+		}
+	}
+
+	setString("baseline", &cfg.BaselineScript, s.Baseline)
+	setString("optimized", &cfg.OptimizedScript, s.Optimized)
+	setString("mode", &cfg.Mode, s.Mode)
+	setString("order", &cfg.Order, s.Order)
+	setString("env-file", &cfg.EnvFile, s.EnvFile)
+	setString("cgroup", &cfg.CgroupMode, s.Cgroup)
+	setString("cpu-max", &cfg.CPUMax, s.CPUMax)
+	setString("cpuset-cpus", &cfg.CPUSetCPUs, s.CPUSetCPUs)
+	setString("memory-max", &cfg.MemoryMax, s.MemoryMax)
+	setString("cpus", &cfg.CPUs, s.CPUs)
+	setString("ionice", &cfg.IONice, s.IONice)
+	setString("artifact-max-size", &cfg.ArtifactMaxSize, s.ArtifactMaxSize)
+	setString("verify", &cfg.Verify, s.Verify)
+	setString("verify-command", &cfg.VerifyCommand, s.VerifyCommand)
+	setString("setup", &cfg.Hooks.Setup, s.Hooks.Setup)
+	setString("teardown", &cfg.Hooks.Teardown, s.Hooks.Teardown)
+	setString("before-run", &cfg.Hooks.BeforeRun, s.Hooks.BeforeRun)
+	setString("after-run", &cfg.Hooks.AfterRun, s.Hooks.AfterRun)
+	for name, h := range s.ScenarioHooks {
+		if cfg.ScenarioHooks == nil {
+			cfg.ScenarioHooks = make(map[string]executor.Hooks)
+		}
+		cfg.ScenarioHooks[name] = executor.Hooks(h)
+	}
+	setInt("nice", &cfg.Nice, s.Nice)
+	setInt("runs", &cfg.MeasuredRuns, s.Runs)
+	setInt("warmup", &cfg.WarmupRuns, s.Warmup)
+	setInt("cooldown-ms", &cfg.CooldownMs, s.CooldownMs)
+	setInt("timeout", &cfg.Timeout, s.Timeout)
+	if len(s.Candidates) > 0 && !flags.Changed("candidate") {
+		cfg.Candidates = nil
+		for _, c := range s.Candidates {
+			cfg.Candidates = append(cfg.Candidates, report.Candidate{Name: c.Name, Script: c.Script})
+		}
+	}
+	if s.Alternate != nil && !flags.Changed("alternate") {
+		cfg.Alternate = *s.Alternate
+	}
+	if s.SameCores != nil && !flags.Changed("same-cores") {
+		cfg.SameCores = *s.SameCores
+	}
+	if s.Artifacts != nil && !flags.Changed("artifacts") {
+		cfg.Artifacts = *s.Artifacts
+	}
+	if s.ArtifactGzip != nil && !flags.Changed("artifact-gzip") {
+		cfg.ArtifactGzip = *s.ArtifactGzip
+	}
+	if s.VerifyChecksum != nil && !flags.Changed("verify-checksum") {
+		cfg.VerifyChecksum = *s.VerifyChecksum
+	}
+	if len(s.Tags) > 0 && !flags.Changed("tag") {
+		cfg.Tags = s.Tags
+	}
+	if len(s.Collect) > 0 && !flags.Changed("collect") {
+		cfg.Collect = parseCollect(strings.Join(s.Collect, ","))
+	}
+	if len(s.Collectors) > 0 && !flags.Changed("collector") {
+		cfg.Collectors = nil
+		for _, c := range s.Collectors {
+			cfg.Collectors = append(cfg.Collectors, report.Collector{Name: c.Name, Command: c.Command})
+		}
+	}
+
+	return cfg, nil
+}
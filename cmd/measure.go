@@ -0,0 +1,491 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/processgain/internal/cgroup"
+	"github.com/processgain/internal/collect"
+	"github.com/processgain/internal/ebpf"
+	"github.com/processgain/internal/executor"
+	"github.com/processgain/internal/report"
+	"github.com/processgain/internal/stats"
+	"github.com/processgain/internal/verify"
+)
+
+// scenarioSamples accumulates the measured runs of one scenario.
+type scenarioSamples struct {
+	name    string
+	label   string
+	script  string
+	results []executor.RunResult
+	ebpf    []ebpf.Metrics
+	// collected holds the values of non-insight collectors, per run
+	collected map[string][][]collect.Value
+	// cgroup, when set, is the scenario's own cgroup v2 group
+	cgroup *cgroup.Group
+	// hooks are the scenario's commands run outside the timed window
+	hooks        executor.Hooks
+	setUp        bool
+	setupHook    *executor.HookResult
+	teardownHook *executor.HookResult
+}
+
+// Orders of the scenarios within the rounds of alternating runs.
+const (
+	// OrderRoundRobin runs the scenarios in the same order every round
+	// (A/B/A/B).
+	OrderRoundRobin = "round-robin"
+	// OrderABBA reverses the order every other round (A/B/B/A), so that
+	// each scenario runs first as often as last.
+	OrderABBA = "abba"
+	// OrderRandom draws a seeded random permutation for every round.
+	OrderRandom = "random"
+)
+
+// Cgroup modes of --cgroup.
+const (
+	// CgroupScenario runs every run of a scenario in the scenario's group.
+	CgroupScenario = "scenario"
+	// CgroupRun gives each run a transient group below the scenario's.
+	CgroupRun = "run"
+)
+
+// measurer executes single measured runs, wrapping each one with the
+// collectors.
+type measurer struct {
+	exec       *executor.Executor
+	mode       string
+	collectors collect.Set
+	order      string
+	rng        *rand.Rand
+	// runCgroups creates a transient group per run, with limits applied
+	runCgroups bool
+	limits     cgroup.Limits
+	groups     int
+	// artifactDir, when set, receives a directory with the output files of
+	// each measured run
+	artifactDir string
+	// verifier, when set, checks the output of each successful run
+	verifier *verify.Checker
+	// executed records the scenario of each measured run, in order.
+	executed []string
+}
+
+// measure runs the scenario once and records the result. progress is the
+// "[i/n]" prefix shown on the console.
+func (m *measurer) measure(s *scenarioSamples, progress string) {
+	red := color.New(color.FgRed)
+	yellow := color.New(color.FgYellow)
+
+	if m.interrupted() {
+		return
+	}
+
+	fmt.Printf("   %s %s...", progress, s.label)
+	m.executed = append(m.executed, s.name)
+	group, err := m.cgroupFor(s)
+	if err != nil {
+		red.Printf(" FAILED: %v\n", err)
+		s.results = append(s.results, executor.RunResult{Error: err.Error()})
+		return
+	}
+	var target collect.Target
+	if group != nil {
+		target.CgroupDir, target.CgroupID = group.Path, group.ID
+		if group != s.cgroup {
+			defer group.Remove()
+		}
+	}
+	run := len(s.results) + 1
+	var hooks []executor.HookResult
+	if h := m.runHook(s, executor.HookBeforeRun, s.hooks.BeforeRun, strconv.Itoa(run)); h != nil {
+		hooks = append(hooks, *h)
+		if h.Error != "" {
+			err := fmt.Errorf("before_run hook failed: %s", h.Error)
+			red.Printf(" FAILED: %v\n", err)
+			printHookOutput(h)
+			s.results = append(s.results, executor.RunResult{Error: err.Error(), Hooks: hooks})
+			m.exec.Cooldown()
+			return
+		}
+	}
+	if m.artifactDir != "" {
+		m.exec.ArtifactDir = filepath.Join(m.artifactDir, fmt.Sprintf("%s-%02d", sanitizeName(s.name), run))
+	}
+	if m.verifier != nil && m.verifier.File() != "" {
+		os.Remove(m.verifier.File())
+	}
+	if err := m.collectors.Start(target); err != nil {
+		red.Printf(" collector failed to start: %v...", err)
+	}
+	result, err := m.exec.RunInCgroup(s.script, m.mode, target.CgroupDir, group != s.cgroup)
+	if result.Artifacts != nil {
+		m.relativize(result.Artifacts)
+	}
+	if len(m.collectors) > 0 {
+		sample, values := m.collectors.Stop()
+		if sample.Insights != nil {
+			s.ebpf = append(s.ebpf, *sample.Insights)
+		}
+		for _, c := range sample.Counters {
+			if result.Counters == nil {
+				result.Counters = make(map[string]float64)
+			}
+			result.Counters[c.Name] = c.Value
+		}
+		for name, v := range values {
+			if s.collected == nil {
+				s.collected = make(map[string][][]collect.Value)
+			}
+			s.collected[name] = append(s.collected[name], v)
+		}
+	}
+	if err != nil {
+		red.Printf(" FAILED: %v\n", err)
+		result.Error = err.Error()
+	} else {
+		fmt.Printf(" %s\n", formatRunValue(result))
+	}
+	if m.verifier != nil && result.Error == "" {
+		m.verify(s, result)
+	}
+	result.Output = nil
+	if result.Error != "" && result.Artifacts != nil {
+		fmt.Printf("     ↳ output: %s\n", filepath.Join(filepath.Dir(m.artifactDir), result.Artifacts.Combined))
+	}
+	if result.MetricsError != "" {
+		for _, line := range strings.Split(result.MetricsError, "\n") {
+			yellow.Printf("     ⚠ %s\n", line)
+		}
+	}
+	if len(result.Strays) > 0 {
+		yellow.Printf("     ⚠ stopped %d stray processes: %s\n", len(result.Strays), executor.FormatProcesses(result.Strays))
+	}
+	if h := m.runHook(s, executor.HookAfterRun, s.hooks.AfterRun, strconv.Itoa(run)); h != nil {
+		hooks = append(hooks, *h)
+		if h.Error != "" {
+			yellow.Printf("     ⚠ after_run hook failed: %s\n", h.Error)
+		}
+	}
+	result.Hooks = hooks
+	s.results = append(s.results, result)
+	m.exec.Cooldown()
+}
+
+// runHook runs one of the scenario's hooks, unless it is not set (nil is
+// returned). The scenario name and the run ("1", "2", ... or "warmup") are
+// passed in CORECUT_SCENARIO and CORECUT_RUN.
+func (m *measurer) runHook(s *scenarioSamples, hook, command, run string) *executor.HookResult {
+	if command == "" {
+		return nil
+	}
+	env := []string{"CORECUT_SCENARIO=" + s.name}
+	if run != "" {
+		env = append(env, "CORECUT_RUN="+run)
+	}
+	h := m.exec.RunHook(hook, command, env...)
+	return &h
+}
+
+// setup runs the setup hook of every scenario, in order, before the first
+// run. When one fails, the scenarios already set up are torn down.
+func (m *measurer) setup(scenarios []*scenarioSamples) error {
+	red := color.New(color.FgRed)
+
+	header := "\n🔧 Setup...\n"
+	for _, s := range scenarios {
+		if s.hooks.Setup == "" {
+			s.setUp = true
+			continue
+		}
+		fmt.Printf("%s   Setup %s...", header, strings.ToLower(s.label))
+		header = ""
+		s.setupHook = m.runHook(s, executor.HookSetup, s.hooks.Setup, "")
+		if s.setupHook.Error != "" {
+			red.Printf(" FAILED: %s\n", s.setupHook.Error)
+			printHookOutput(s.setupHook)
+			m.teardown(scenarios)
+			return fmt.Errorf("setup of %s failed: %s", s.name, s.setupHook.Error)
+		}
+		s.setUp = true
+		fmt.Printf(" done (%.0fms)\n", s.setupHook.DurationMs)
+	}
+	return nil
+}
+
+// teardown runs the teardown hook of the scenarios set up, in reverse
+// order. It runs once: later calls do nothing.
+func (m *measurer) teardown(scenarios []*scenarioSamples) {
+	yellow := color.New(color.FgYellow)
+
+	header := "\n🔧 Teardown...\n"
+	for i := len(scenarios) - 1; i >= 0; i-- {
+		s := scenarios[i]
+		if !s.setUp {
+			continue
+		}
+		s.setUp = false
+		if s.hooks.Teardown == "" {
+			continue
+		}
+		fmt.Printf("%s   Teardown %s...", header, strings.ToLower(s.label))
+		header = ""
+		s.teardownHook = m.runHook(s, executor.HookTeardown, s.hooks.Teardown, "")
+		if s.teardownHook.Error != "" {
+			yellow.Printf(" FAILED: %s\n", s.teardownHook.Error)
+			printHookOutput(s.teardownHook)
+		} else {
+			fmt.Printf(" done (%.0fms)\n", s.teardownHook.DurationMs)
+		}
+	}
+}
+
+// printHookOutput shows the output of a failed hook, indented.
+func printHookOutput(h *executor.HookResult) {
+	for _, line := range strings.Split(strings.TrimSpace(h.Output), "\n") {
+		if line != "" {
+			fmt.Printf("       %s\n", line)
+		}
+	}
+}
+
+// relativize makes the artifact paths relative to the report directory, so
+// that the report links to them wherever it is moved along with them.
+func (m *measurer) relativize(a *executor.Artifacts) {
+	for _, p := range []*string{&a.Stdout, &a.Stderr, &a.Combined} {
+		if rel, err := filepath.Rel(filepath.Dir(m.artifactDir), *p); err == nil {
+			*p = rel
+		}
+	}
+}
+
+// verify hands the output of a successful run to the checker: its stdout,
+// without the lines meant for CoreCut, or the file it wrote.
+func (m *measurer) verify(s *scenarioSamples, result executor.RunResult) {
+	data, err := executor.ScriptOutput(result.Output), error(nil)
+	if file := m.verifier.File(); file != "" {
+		if data, err = os.ReadFile(file); err != nil {
+			err = fmt.Errorf("failed to read output: %w", err)
+		}
+	}
+	m.verifier.Check(s.name, len(s.results)+1, data, err)
+}
+
+// interrupted reports whether Ctrl-C stopped the benchmark.
+func (m *measurer) interrupted() bool {
+	return m.exec.Context != nil && m.exec.Context.Err() != nil
+}
+
+// warmup runs the scenario once, in the same cgroup setup as the measured
+// runs but without the collectors.
+func (m *measurer) warmup(s *scenarioSamples) error {
+	group, err := m.cgroupFor(s)
+	if err != nil {
+		return err
+	}
+	var dir string
+	if group != nil {
+		dir = group.Path
+		if group != s.cgroup {
+			defer group.Remove()
+		}
+	}
+	defer m.exec.Cooldown()
+	if h := m.runHook(s, executor.HookBeforeRun, s.hooks.BeforeRun, "warmup"); h != nil && h.Error != "" {
+		return fmt.Errorf("before_run hook failed: %s", h.Error)
+	}
+	_, err = m.exec.RunInCgroup(s.script, m.mode, dir, group != s.cgroup)
+	if h := m.runHook(s, executor.HookAfterRun, s.hooks.AfterRun, "warmup"); h != nil && h.Error != "" && err == nil {
+		err = fmt.Errorf("after_run hook failed: %s", h.Error)
+	}
+	return err
+}
+
+// cgroupFor returns the group the next run of s goes in: the scenario's own,
+// or a new transient group below it with --cgroup run.
+func (m *measurer) cgroupFor(s *scenarioSamples) (*cgroup.Group, error) {
+	if !m.runCgroups || s.cgroup == nil {
+		return s.cgroup, nil
+	}
+	m.groups++
+	g, err := s.cgroup.Child(fmt.Sprintf("run-%d", m.groups))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create run cgroup: %w", err)
+	}
+	if err := g.Apply(m.limits); err != nil {
+		g.Remove()
+		return nil, err
+	}
+	return g, nil
+}
+
+// measureBatch runs n more runs of each scenario, either interleaved in
+// rounds ordered by --order or one scenario after the other. done is the
+// number of runs per scenario already measured and progress labels the
+// 1-based run (or round) number.
+func (m *measurer) measureBatch(scenarios []*scenarioSamples, n, done int, alternate bool, progress func(run int) string) {
+	if alternate {
+		round := make([]*scenarioSamples, len(scenarios))
+		for i := 0; i < n; i++ {
+			label := progress(done + i + 1)
+			copy(round, scenarios)
+			switch m.order {
+			case OrderABBA:
+				// Rounds are numbered across batches so blocks stay intact
+				if (done+i)%2 == 1 {
+					for a, b := 0, len(round)-1; a < b; a, b = a+1, b-1 {
+						round[a], round[b] = round[b], round[a]
+					}
+				}
+			case OrderRandom:
+				m.rng.Shuffle(len(round), func(a, b int) { round[a], round[b] = round[b], round[a] })
+			}
+			for _, s := range round {
+				m.measure(s, label)
+			}
+		}
+		return
+	}
+
+	for _, s := range scenarios {
+		for i := 0; i < n; i++ {
+			m.measure(s, progress(done+i+1))
+		}
+	}
+}
+
+// measureAdaptive measures in batches until the primary gain of every
+// candidate is resolved: all comparisons are conclusive or all their CIs are
+// narrower than --target-ci-width. It always stops at --max-runs and before a
+// batch would exceed --budget. scenarios[0] is the baseline and compare
+// returns one comparison per candidate.
+func measureAdaptive(m *measurer, cfg report.Config, scenarios []*scenarioSamples, compare func() []stats.Comparison) report.Sampling {
+	start := time.Now()
+	sampling := report.Sampling{Adaptive: true}
+
+	for {
+		n := cfg.BatchSize
+		if sampling.Runs < cfg.MinRuns {
+			n = cfg.MinRuns - sampling.Runs
+		}
+		if sampling.Runs+n > cfg.MaxRuns {
+			n = cfg.MaxRuns - sampling.Runs
+		}
+
+		// The final count is unknown: show the bounds sampling stops within
+		batch, unit := sampling.Batches+1, "run"
+		if cfg.Alternate {
+			unit = "round"
+		}
+		m.measureBatch(scenarios, n, sampling.Runs, cfg.Alternate, func(run int) string {
+			return fmt.Sprintf("[batch %d, %s %d (min %d, max %d)]", batch, unit, run, cfg.MinRuns, cfg.MaxRuns)
+		})
+		if m.interrupted() {
+			return sampling
+		}
+		sampling.Runs += n
+		sampling.Batches++
+
+		if sampling.Runs < cfg.MinRuns {
+			continue
+		}
+
+		conclusive, width := true, 0.0
+		for i, comp := range compare() {
+			name := ""
+			if len(scenarios) > 2 {
+				name = scenarios[i+1].label + " "
+			}
+			if !comp.HasGain() {
+				// Nothing to resolve (yet): keep sampling
+				fmt.Printf("   ↳ after %d runs: %sgain %s\n", sampling.Runs, name, formatGain("%.2f%%", comp))
+				conclusive, width = false, math.Inf(1)
+				continue
+			}
+			w := comp.GainCIHigh - comp.GainCILow
+			fmt.Printf("   ↳ after %d runs: %sgain %.2f%%, CI [%.2f%%, %.2f%%] (width %.2f)\n",
+				sampling.Runs, name, comp.GainPercent, comp.GainCILow, comp.GainCIHigh, w)
+			conclusive = conclusive && comp.Conclusive
+			width = math.Max(width, w)
+		}
+
+		switch {
+		case conclusive:
+			sampling.StopReason = report.StopConclusive
+		case width <= cfg.TargetCIWidth:
+			sampling.StopReason = report.StopCIWidth
+		case sampling.Runs >= cfg.MaxRuns:
+			sampling.StopReason = report.StopMaxRuns
+		case cfg.BudgetSec > 0 && overBudget(start, sampling.Runs, cfg.BatchSize, time.Duration(cfg.BudgetSec*float64(time.Second))):
+			sampling.StopReason = report.StopTimeBudget
+		}
+		if sampling.StopReason != "" {
+			sampling.ElapsedSec = time.Since(start).Seconds()
+			return sampling
+		}
+	}
+}
+
+// createCgroups gives every scenario its own cgroup v2 group, so that the
+// collectors can be limited to the scenario's process tree and its usage is
+// accounted exactly. The accounted controllers are enabled for them where
+// the hierarchy has them. Limits are applied to the scenario groups, or left
+// for the run groups created below them when perRun is set.
+func createCgroups(scenarios []*scenarioSamples, limits cgroup.Limits, perRun bool) error {
+	parent := fmt.Sprintf("corecut-%d", os.Getpid())
+	for _, s := range scenarios {
+		g, err := cgroup.Create(filepath.Join(parent, sanitizeName(s.name)))
+		if err != nil {
+			removeCgroups(scenarios)
+			return err
+		}
+		s.cgroup = g
+	}
+
+	// The controllers go to the run groups below each scenario's, or to the
+	// scenario groups themselves
+	for _, s := range scenarios {
+		path := filepath.Dir(s.cgroup.Path)
+		if perRun {
+			path = s.cgroup.Path
+		}
+		cgroup.DelegateAvailable(path, cgroup.Accounted)
+		err := cgroup.Delegate(path, limits.Controllers())
+		if err == nil && !perRun {
+			err = s.cgroup.Apply(limits)
+		}
+		if err != nil {
+			removeCgroups(scenarios)
+			return err
+		}
+	}
+	return nil
+}
+
+// removeCgroups deletes the scenario groups and their common parent.
+func removeCgroups(scenarios []*scenarioSamples) {
+	for _, s := range scenarios {
+		if s.cgroup == nil {
+			continue
+		}
+		s.cgroup.Remove()
+		os.Remove(filepath.Dir(s.cgroup.Path))
+		s.cgroup = nil
+	}
+}
+
+// overBudget reports whether another batch, at the average pace so far,
+// would end after the budget.
+func overBudget(start time.Time, done, next int, budget time.Duration) bool {
+	elapsed := time.Since(start)
+	perRun := elapsed / time.Duration(done)
+	return elapsed+perRun*time.Duration(next) > budget
+}
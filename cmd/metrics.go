@@ -0,0 +1,350 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/processgain/internal/executor"
+	"github.com/processgain/internal/perf"
+	"github.com/processgain/internal/report"
+	"github.com/processgain/internal/stats"
+)
+
+// measuredMetric describes a per-run value that is summarized and compared.
+type measuredMetric struct {
+	name      string
+	label     string
+	unit      string
+	direction stats.Direction
+	// key metrics take part in the Pareto verdict, the others are informational.
+	key   bool
+	value func(r executor.RunResult) float64
+	// present, when set, tells whether a run recorded the metric: runs
+	// without it are skipped, and the metric is left out when none has it.
+	present func(r executor.RunResult) bool
+}
+
+func metricForMode(mode string) measuredMetric {
+	if mode == "throughput" {
+		return measuredMetric{
+			name:      "throughput",
+			label:     "Throughput",
+			unit:      "ops/s",
+			direction: stats.HigherIsBetter,
+			key:       true,
+			value:     func(r executor.RunResult) float64 { return r.Throughput },
+			// A run without a THROUGHPUT line parses as 0
+			present: func(r executor.RunResult) bool { return r.Throughput > 0 },
+		}
+	}
+	return wallTimeMetric
+}
+
+var wallTimeMetric = measuredMetric{
+	name:      "duration",
+	label:     "Wall time",
+	unit:      "ms",
+	direction: stats.LowerIsBetter,
+	key:       true,
+	value:     func(r executor.RunResult) float64 { return r.DurationMs },
+}
+
+// metricsForMode lists every metric compared in a report, primary metric
+// first. Resource, cgroup and counter metrics are included when one of the
+// runs recorded them.
+func metricsForMode(mode string, runs ...[]executor.RunResult) []measuredMetric {
+	primary := metricForMode(mode)
+	metrics := []measuredMetric{primary}
+	if primary.name != wallTimeMetric.name {
+		// With a throughput primary the amount of work per run is up to the
+		// script, so wall time is shown but does not decide the verdict.
+		wall := wallTimeMetric
+		wall.key = false
+		metrics = append(metrics, wall)
+	}
+	// The cgroup peak of per-run groups accounts for every process, where
+	// the sampled peak RSS can miss short-lived ones: when every run has it,
+	// it is the key memory metric instead
+	cgroupPeak := cgroupMemoryPeak.recordedByAll(runs)
+	for _, group := range [][]measuredMetric{resourceMetrics, cgroupMetrics, counterMetrics} {
+		for _, om := range group {
+			if !om.recorded(runs) {
+				continue
+			}
+			switch om.name {
+			case maxRSS.name:
+				om.key = !cgroupPeak
+			case cgroupMemoryPeak.name:
+				om.key = cgroupPeak
+			}
+			metrics = append(metrics, om)
+		}
+	}
+	metrics = append(metrics, phaseMetrics(runs)...)
+	return append(metrics, customMetrics(runs)...)
+}
+
+// resourceMetrics are derived from the rusage of each run's process tree.
+var resourceMetrics = []measuredMetric{
+	{
+		name: "cpu_time", label: "CPU time", unit: "ms", direction: stats.LowerIsBetter, key: true,
+		value: func(r executor.RunResult) float64 { return r.Resources.CPUMs() },
+	},
+	{
+		name: "user_cpu_time", label: "User CPU", unit: "ms", direction: stats.LowerIsBetter,
+		value: func(r executor.RunResult) float64 { return r.Resources.UserCPUMs },
+	},
+	{
+		name: "sys_cpu_time", label: "System CPU", unit: "ms", direction: stats.LowerIsBetter,
+		value: func(r executor.RunResult) float64 { return r.Resources.SysCPUMs },
+	},
+	maxRSS,
+	{
+		name: "minor_faults", label: "Minor faults", unit: "count", direction: stats.LowerIsBetter,
+		value: func(r executor.RunResult) float64 { return float64(r.Resources.MinorFaults) },
+	},
+	{
+		name: "major_faults", label: "Major faults", unit: "count", direction: stats.LowerIsBetter,
+		value: func(r executor.RunResult) float64 { return float64(r.Resources.MajorFaults) },
+	},
+	{
+		name: "voluntary_ctx_switches", label: "Voluntary switches", unit: "count", direction: stats.LowerIsBetter,
+		value: func(r executor.RunResult) float64 { return float64(r.Resources.VolCtxSwitches) },
+	},
+	{
+		name: "involuntary_ctx_switches", label: "Involuntary switches", unit: "count", direction: stats.LowerIsBetter,
+		value: func(r executor.RunResult) float64 { return float64(r.Resources.InvolCtxSwitches) },
+	},
+}
+
+// cgroupMetrics are read from the usage of each run's cgroup, which
+// accounts for every process of the run.
+var cgroupMetrics = []measuredMetric{
+	{
+		name: "cgroup_cpu_time", label: "Cgroup CPU time", unit: "ms", direction: stats.LowerIsBetter,
+		value:   func(r executor.RunResult) float64 { return float64(r.Cgroup.CPUUsageUsec) / 1000 },
+		present: func(r executor.RunResult) bool { return r.Cgroup != nil },
+	},
+	cgroupMemoryPeak,
+}
+
+// maxRSS is the peak RSS sampled from the VmHWM of the run's processes.
+var maxRSS = measuredMetric{
+	name: "max_rss", label: "Peak RSS", unit: "MB", direction: stats.LowerIsBetter, key: true,
+	value:   func(r executor.RunResult) float64 { return float64(r.Resources.MaxRSSKB) / 1024.0 },
+	present: func(r executor.RunResult) bool { return r.Resources.MaxRSSKB > 0 },
+}
+
+// cgroupMemoryPeak is the memory.peak of the run's cgroup, recorded where it
+// is the run's own: a fresh group, or one whose peak could be reset.
+var cgroupMemoryPeak = measuredMetric{
+	name: "cgroup_memory_peak", label: "Cgroup memory peak", unit: "MB", direction: stats.LowerIsBetter,
+	value:   func(r executor.RunResult) float64 { return float64(r.Cgroup.MemoryPeakBytes) / (1 << 20) },
+	present: func(r executor.RunResult) bool { return r.Cgroup != nil && r.Cgroup.MemoryPeakBytes > 0 },
+}
+
+// counterMetrics are read from the perf event counters of each run. They
+// explain a wall time change (less work, or better use of the CPU) rather
+// than decide the verdict.
+var counterMetrics = []measuredMetric{
+	counterMetric(perf.Instructions, "Instructions", "count", stats.LowerIsBetter),
+	counterMetric(perf.Cycles, "Cycles", "count", stats.LowerIsBetter),
+	counterMetric(perf.IPC, "IPC", "ratio", stats.HigherIsBetter),
+	counterMetric(perf.CacheMisses, "Cache misses", "count", stats.LowerIsBetter),
+	counterMetric(perf.BranchMisses, "Branch misses", "count", stats.LowerIsBetter),
+	counterMetric(perf.TaskClock, "Task clock", "ms", stats.LowerIsBetter),
+	counterMetric(perf.ContextSwitches, "Context switches", "count", stats.LowerIsBetter),
+	counterMetric(perf.CPUMigrations, "CPU migrations", "count", stats.LowerIsBetter),
+}
+
+// phaseMetrics are the durations of the phases the scripts marked, one per
+// phase name in the order they were first seen. Like the counters they
+// explain a wall time change rather than decide the verdict.
+func phaseMetrics(runs [][]executor.RunResult) []measuredMetric {
+	var metrics []measuredMetric
+	seen := make(map[string]bool)
+	for _, results := range runs {
+		for _, r := range results {
+			for _, p := range r.Phases {
+				if !seen[p.Name] {
+					seen[p.Name] = true
+					metrics = append(metrics, phaseMetric(p.Name))
+				}
+			}
+		}
+	}
+	return metrics
+}
+
+func phaseMetric(name string) measuredMetric {
+	return measuredMetric{
+		name: "phase:" + name, label: "Phase " + name, unit: "ms", direction: stats.LowerIsBetter,
+		value: func(r executor.RunResult) float64 {
+			p, _ := r.Phase(name)
+			return p.DurationMs
+		},
+		present: func(r executor.RunResult) bool {
+			_, ok := r.Phase(name)
+			return ok
+		},
+	}
+}
+
+// customMetrics are the metrics the scripts reported themselves, one per
+// name in the order they were first seen. The unit and direction are those
+// of the first report.
+func customMetrics(runs [][]executor.RunResult) []measuredMetric {
+	var metrics []measuredMetric
+	seen := make(map[string]bool)
+	for _, results := range runs {
+		for _, r := range results {
+			for _, cm := range r.CustomMetrics {
+				if !seen[cm.Name] {
+					seen[cm.Name] = true
+					metrics = append(metrics, customMetric(cm))
+				}
+			}
+		}
+	}
+	return metrics
+}
+
+func customMetric(cm executor.CustomMetric) measuredMetric {
+	direction := stats.LowerIsBetter
+	if cm.Better == executor.BetterHigher {
+		direction = stats.HigherIsBetter
+	}
+	unit := cm.Unit
+	if unit == "" {
+		unit = "value"
+	}
+	name := cm.Name
+	return measuredMetric{
+		name: "custom:" + name, label: name, unit: unit, direction: direction,
+		value: func(r executor.RunResult) float64 {
+			m, _ := r.CustomMetric(name)
+			return m.Value
+		},
+		present: func(r executor.RunResult) bool {
+			_, ok := r.CustomMetric(name)
+			return ok
+		},
+	}
+}
+
+// recorded reports whether any of the runs recorded the metric.
+func (m measuredMetric) recorded(runs [][]executor.RunResult) bool {
+	for _, results := range runs {
+		for _, r := range results {
+			if m.present == nil || m.present(r) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// recordedByAll reports whether every run, failed ones aside, recorded the
+// metric.
+func (m measuredMetric) recordedByAll(runs [][]executor.RunResult) bool {
+	seen := false
+	for _, results := range runs {
+		for _, r := range results {
+			if r.Error != "" {
+				continue
+			}
+			if m.present != nil && !m.present(r) {
+				return false
+			}
+			seen = true
+		}
+	}
+	return seen
+}
+
+func counterMetric(name, label, unit string, direction stats.Direction) measuredMetric {
+	return measuredMetric{
+		name: name, label: label, unit: unit, direction: direction,
+		value: func(r executor.RunResult) float64 { return r.Counters[name] },
+		present: func(r executor.RunResult) bool {
+			_, ok := r.Counters[name]
+			return ok
+		},
+	}
+}
+
+// sample returns the metric value of a run and whether it counts: failed
+// runs and runs that did not report a value (e.g. no THROUGHPUT line) are
+// skipped rather than counted as 0.
+func (m measuredMetric) sample(r executor.RunResult) (float64, bool) {
+	if r.Error != "" {
+		return 0, false
+	}
+	if m.present != nil && !m.present(r) {
+		return 0, false
+	}
+	return m.value(r), true
+}
+
+// extract returns the metric value of every run that counts.
+func (m measuredMetric) extract(results []executor.RunResult) []float64 {
+	values := make([]float64, 0, len(results))
+	for _, r := range results {
+		if v, ok := m.sample(r); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// compare compares the metric of two scenarios. With alternating runs the
+// i-th run of each scenario belongs to round i: a round is kept only when
+// both of its runs count, so that the paired tests compare runs of the same
+// round, and the samples are compared unpaired when no round is complete.
+func (m measuredMetric) compare(baseline, optimized []executor.RunResult, opts stats.Options) stats.Comparison {
+	opts = m.options(opts)
+	if opts.Alternate {
+		var baselineValues, optimizedValues []float64
+		for i := 0; i < min(len(baseline), len(optimized)); i++ {
+			b, bok := m.sample(baseline[i])
+			o, ook := m.sample(optimized[i])
+			if bok && ook {
+				baselineValues = append(baselineValues, b)
+				optimizedValues = append(optimizedValues, o)
+			}
+		}
+		if len(baselineValues) > 0 {
+			return stats.Compare(baselineValues, optimizedValues, opts)
+		}
+		opts.Alternate = false
+	}
+	return stats.Compare(m.extract(baseline), m.extract(optimized), opts)
+}
+
+// summarize computes per-scenario stats for the metric and compares them.
+// opts carries the run-wide comparison settings; the direction is the metric's.
+func (m measuredMetric) summarize(baseline, optimized []executor.RunResult, opts stats.Options) report.MetricResult {
+	baselineValues := m.extract(baseline)
+	optimizedValues := m.extract(optimized)
+
+	return report.MetricResult{
+		Name:       m.name,
+		Label:      m.label,
+		Unit:       m.unit,
+		Key:        m.key,
+		Baseline:   stats.Calculate(baselineValues),
+		Optimized:  stats.Calculate(optimizedValues),
+		Comparison: m.compare(baseline, optimized, opts),
+	}
+}
+
+func (m measuredMetric) options(opts stats.Options) stats.Options {
+	opts.Direction = m.direction
+	return opts
+}
+
+func formatRunValue(r executor.RunResult) string {
+	if r.Throughput > 0 {
+		return fmt.Sprintf("%.2f ops/s (%.2fms)", r.Throughput, r.DurationMs)
+	}
+	return fmt.Sprintf("%.2fms", r.DurationMs)
+}
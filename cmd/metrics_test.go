@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/processgain/internal/cgroup"
+	"github.com/processgain/internal/executor"
+	"github.com/processgain/internal/stats"
+)
+
+func TestCompareKeepsRoundsAligned(t *testing.T) {
+	run := func(ms float64) executor.RunResult { return executor.RunResult{DurationMs: ms} }
+	failed := executor.RunResult{Error: "exit status 1"}
+	// Both scenarios lose one run, in different rounds: only rounds 1 and 3
+	// are complete
+	baseline := []executor.RunResult{failed, run(100), run(200), run(300)}
+	optimized := []executor.RunResult{run(90), run(95), failed, run(150)}
+
+	comp := wallTimeMetric.compare(baseline, optimized, stats.Options{Alternate: true, Seed: 1})
+	wantW, wantP := stats.Wilcoxon([]float64{100, 300}, []float64{95, 150})
+	if comp.Test != stats.TestWilcoxon || comp.WilcoxonW != wantW || comp.WilcoxonP != wantP {
+		t.Errorf("compare() = %s W=%v p=%v, want %s W=%v p=%v", comp.Test, comp.WilcoxonW, comp.WilcoxonP, stats.TestWilcoxon, wantW, wantP)
+	}
+
+	// No complete round: the samples are compared unpaired
+	comp = wallTimeMetric.compare([]executor.RunResult{run(100), failed}, []executor.RunResult{failed, run(90)}, stats.Options{Alternate: true, Seed: 1})
+	if comp.Test != stats.TestMannWhitney {
+		t.Errorf("compare() without complete rounds used %s, want %s", comp.Test, stats.TestMannWhitney)
+	}
+}
+
+func TestExtractKeepsZeroValues(t *testing.T) {
+	runs := []executor.RunResult{
+		{Throughput: 120, CustomMetrics: []executor.CustomMetric{{Name: "hits", Value: 0, Better: executor.BetterHigher}}},
+		// No THROUGHPUT line
+		{CustomMetrics: []executor.CustomMetric{{Name: "hits", Value: 3, Better: executor.BetterHigher}}},
+	}
+	if got := metricForMode("throughput").extract(runs); len(got) != 1 || got[0] != 120 {
+		t.Errorf("throughput extract() = %v, want [120]", got)
+	}
+	hits := customMetrics([][]executor.RunResult{runs})[0]
+	if got := hits.extract(runs); len(got) != 2 || got[0] != 0 || got[1] != 3 {
+		t.Errorf("custom extract() = %v, want [0 3]", got)
+	}
+}
+
+func TestMemoryKeyMetric(t *testing.T) {
+	run := func(peak uint64) executor.RunResult {
+		r := executor.RunResult{Resources: executor.ResourceUsage{MaxRSSKB: 4096}}
+		if peak > 0 {
+			r.Cgroup = &cgroup.Stats{MemoryPeakBytes: peak}
+		}
+		return r
+	}
+	keys := func(runs ...[]executor.RunResult) map[string]bool {
+		k := make(map[string]bool)
+		for _, m := range metricsForMode("duration", runs...) {
+			k[m.name] = m.key
+		}
+		return k
+	}
+
+	// Per-run cgroup peaks on every run: the cgroup peak is the key metric
+	k := keys([]executor.RunResult{run(8 << 20), run(9 << 20)}, []executor.RunResult{run(7 << 20)})
+	if !k["cgroup_memory_peak"] || k["max_rss"] {
+		t.Errorf("with cgroup peaks, keys = %v, want cgroup_memory_peak only", k)
+	}
+	// A run without one: the sampled peak RSS stays the key metric
+	k = keys([]executor.RunResult{run(8 << 20), run(0)}, []executor.RunResult{run(7 << 20)})
+	if k["cgroup_memory_peak"] || !k["max_rss"] {
+		t.Errorf("with missing cgroup peaks, keys = %v, want max_rss only", k)
+	}
+}
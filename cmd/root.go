@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/processgain/internal/collect"
+	"github.com/processgain/internal/ebpf"
 	"github.com/spf13/cobra"
 )
 
@@ -44,16 +46,41 @@ var checkDepsCmd = &cobra.Command{
 
 func checkDependencies() {
 	fmt.Println("Checking dependencies...")
-	
+
+	// The native collector only needs root and kernel BTF
+	collector := ebpf.NewCollector()
+	defer collector.Close()
+	if collector.Backend() == ebpf.BackendNative {
+		fmt.Println("  [✓] native eBPF collector (kernel BTF, raw tracepoints)")
+	} else if err := collector.NativeError(); err != nil {
+		fmt.Printf("  [✗] native eBPF collector: %v\n", err)
+	} else {
+		fmt.Println("  [?] native eBPF collector - needs root")
+	}
+
+	// Collectors available to --collect
+	fmt.Println("\nCollectors (--collect):")
+	for _, name := range collect.Names() {
+		c, _ := collect.New(name)
+		if err := c.Probe(); err != nil {
+			fmt.Printf("  [✗] %-8s %s: %v\n", name, collect.Description(name), err)
+		} else {
+			fmt.Printf("  [✓] %-8s %s\n", name, collect.Description(name))
+		}
+		c.Close()
+	}
+	fmt.Println()
+
 	deps := []struct {
-		name    string
-		cmd     string
+		name     string
+		cmd      string
 		required bool
 	}{
 		{"bpftrace", "bpftrace --version", false},
 		{"bcc-tools (runqlat)", "which runqlat", false},
 		{"bcc-tools (biolatency)", "which biolatency", false},
 		{"bcc-tools (offcputime)", "which offcputime", false},
+		{"bcc-tools (syscount)", "which syscount", false},
 	}
 
 	allFound := true
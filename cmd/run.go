@@ -1,35 +1,80 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/olekukonko/tablewriter"
+	"github.com/processgain/internal/cgroup"
+	"github.com/processgain/internal/collect"
 	"github.com/processgain/internal/ebpf"
 	"github.com/processgain/internal/executor"
 	"github.com/processgain/internal/report"
+	"github.com/processgain/internal/spec"
 	"github.com/processgain/internal/stats"
+	"github.com/processgain/internal/verify"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
-	baselineScript  string
-	optimizedScript string
-	warmupRuns      int
-	runs            int
-	alternate       bool
-	cooldownMs      int
-	timeout         int
-	envFile         string
-	tag             string
-	mode            string
-	outputDir       string
-	noEbpf          bool
-	machineName     string
+	baselineScript   string
+	optimizedScript  string
+	warmupRuns       int
+	runs             int
+	alternate        bool
+	cooldownMs       int
+	timeout          int
+	envFile          string
+	tag              string
+	mode             string
+	outputDir        string
+	noEbpf           bool
+	machineName      string
+	verdictThreshold float64
+	confidence       float64
+	resamples        int
+	seed             int64
+	alpha            float64
+	minEffect        float64
+	adaptive         bool
+	minRuns          int
+	maxRuns          int
+	batchSize        int
+	targetCIWidth    float64
+	budget           time.Duration
+	specFile         string
+	candidateFlags   []string
+	order            string
+	collectList      string
+	collectorFlags   []string
+	cgroupMode       string
+	cpuMax           string
+	cpusetCPUs       string
+	memoryMax        string
+	cpuList          string
+	sameCores        bool
+	niceValue        int
+	ioNice           string
+	artifacts        bool
+	artifactMaxSize  string
+	artifactGzip     bool
+	verifySource     string
+	verifyCommand    string
+	verifyChecksum   bool
+	hooks            executor.Hooks
 )
 
 var runCmd = &cobra.Command{
@@ -38,17 +83,28 @@ var runCmd = &cobra.Command{
 	Long: `Execute baseline and optimized scenarios with proper warmup, alternation,
 and statistical analysis. Collects eBPF metrics when available.
 
+With -f, the suites of a corecut.yaml spec file are run one after the other.
+Flags given explicitly on the command line override the values from the file.
+Any number of named candidates can be compared against the baseline with
+--candidate; they are ranked by gain, with p-values corrected for multiple
+comparisons.
+
 Example:
-  processgain run --baseline ./baseline.sh --optimized ./optimized.sh --runs 9 --warmup 1 --alternate`,
+  processgain run --baseline ./baseline.sh --optimized ./optimized.sh --runs 9 --warmup 1 --alternate
+  processgain run --baseline ./v1.sh --candidate simd=./v2.sh --candidate pool=./v3.sh --order random
+  processgain run -f corecut.yaml --runs 15`,
 	RunE: runBenchmark,
 }
 
 func init() {
-	runCmd.Flags().StringVarP(&baselineScript, "baseline", "b", "", "Path to baseline scenario script (required)")
-	runCmd.Flags().StringVarP(&optimizedScript, "optimized", "o", "", "Path to optimized scenario script (required)")
+	runCmd.Flags().StringVarP(&specFile, "file", "f", "", "Spec file (corecut.yaml) describing one or more suites")
+	runCmd.Flags().StringVarP(&baselineScript, "baseline", "b", "", "Path to baseline scenario script (required without --file)")
+	runCmd.Flags().StringVarP(&optimizedScript, "optimized", "o", "", "Path to optimized scenario script (required without --file or --candidate)")
 	runCmd.Flags().IntVarP(&warmupRuns, "warmup", "w", 1, "Number of warmup runs (discarded)")
 	runCmd.Flags().IntVarP(&runs, "runs", "r", 9, "Number of measured runs per scenario")
+	runCmd.Flags().StringArrayVar(&candidateFlags, "candidate", nil, "Extra candidate compared against the baseline, as name=path (repeatable)")
 	runCmd.Flags().BoolVarP(&alternate, "alternate", "a", true, "Alternate A/B/A/B execution (recommended)")
+	runCmd.Flags().StringVar(&order, "order", OrderRoundRobin, "Scenario order within alternating rounds: round-robin (A/B/A/B), abba (A/B/B/A), random (seeded per round)")
 	runCmd.Flags().IntVar(&cooldownMs, "cooldown-ms", 500, "Cooldown between runs in milliseconds")
 	runCmd.Flags().IntVarP(&timeout, "timeout", "t", 300, "Timeout per run in seconds")
 	runCmd.Flags().StringVar(&envFile, "env-file", "", "Environment file to source before runs")
@@ -56,30 +112,57 @@ func init() {
 	runCmd.Flags().StringVarP(&mode, "mode", "m", "duration", "Measurement mode: duration, throughput")
 	runCmd.Flags().StringVar(&outputDir, "output", "./reports", "Output directory for reports")
 	runCmd.Flags().BoolVar(&noEbpf, "no-ebpf", false, "Disable eBPF collection")
+	runCmd.Flags().StringVar(&collectList, "collect", collectAuto, "Comma-separated collectors to run (see check-deps), auto: ebpf (else proc), perf and psi, or none (only --collector ones)")
+	runCmd.Flags().StringArrayVar(&collectorFlags, "collector", nil, "User-defined collector command, as name=command (repeatable)")
+	runCmd.Flags().StringVar(&cgroupMode, "cgroup", "", "Run in a dedicated cgroup v2 group per scenario or per run: scenario, run (default: only when a collector needs it)")
+	runCmd.Flags().StringVar(&cpuMax, "cpu-max", "", "cgroup cpu.max of the scenarios, as \"quota period\" in µs (implies --cgroup scenario)")
+	runCmd.Flags().StringVar(&cpusetCPUs, "cpuset-cpus", "", "cgroup cpuset.cpus of the scenarios, e.g. 0-3 (implies --cgroup scenario)")
+	runCmd.Flags().StringVar(&memoryMax, "memory-max", "", "cgroup memory.max of the scenarios, e.g. 2G (implies --cgroup scenario)")
+	runCmd.Flags().StringVar(&cpuList, "cpus", "", "Pin the scenarios to a CPU list, e.g. 2-3 (sched_setaffinity)")
+	runCmd.Flags().BoolVar(&sameCores, "same-cores", false, "Pin every scenario to the same cores: all of --cpus, or of the CPUs available")
+	runCmd.Flags().IntVar(&niceValue, "nice", 0, "Nice value of the scenarios (negative values need root)")
+	runCmd.Flags().StringVar(&ioNice, "ionice", "", "I/O scheduling class of the scenarios: realtime[:0-7], best-effort[:0-7], idle")
+	runCmd.Flags().BoolVar(&artifacts, "artifacts", false, "Keep the full stdout, stderr and a timestamped combined log of every measured run next to the report")
+	runCmd.Flags().StringVar(&artifactMaxSize, "artifact-max-size", "10M", "Size cap of each artifact file, before compression (0 = unlimited)")
+	runCmd.Flags().BoolVar(&artifactGzip, "artifact-gzip", false, "Compress the artifact files with gzip")
+	runCmd.Flags().StringVar(&verifySource, "verify", "", "Check that every scenario produces the baseline's output: stdout, or file:PATH for a file the scenarios write")
+	runCmd.Flags().StringVar(&verifyCommand, "verify-command", "", "Checker command run as CMD REFERENCE OUTPUT, exit status 0 when equivalent (implies --verify stdout)")
+	runCmd.Flags().BoolVar(&verifyChecksum, "verify-checksum", false, "Compare the SHA-256 of the outputs only, for large or binary output")
+	runCmd.Flags().StringVar(&hooks.Setup, "setup", "", "Command run once per scenario before its first run, untimed (CORECUT_SCENARIO is set)")
+	runCmd.Flags().StringVar(&hooks.Teardown, "teardown", "", "Command run once per scenario after its last run, untimed")
+	runCmd.Flags().StringVar(&hooks.BeforeRun, "before-run", "", "Command run before each run of every scenario, untimed (CORECUT_RUN is set)")
+	runCmd.Flags().StringVar(&hooks.AfterRun, "after-run", "", "Command run after each run of every scenario, untimed")
 	runCmd.Flags().StringVar(&machineName, "machine", "", "Machine name (auto-detected if empty)")
+	runCmd.Flags().Float64Var(&confidence, "confidence", stats.DefaultConfidence, "Confidence level of the bootstrap gain interval")
+	runCmd.Flags().IntVar(&resamples, "bootstrap-resamples", stats.DefaultResamples, "Number of bootstrap resamples for the gain interval")
+	runCmd.Flags().Int64Var(&seed, "seed", 0, "Random seed for bootstrap resampling and --order random (0 = derive from clock; the seed used is recorded in the report)")
+	runCmd.Flags().Float64Var(&alpha, "alpha", stats.DefaultAlpha, "Significance level of the rank test (Mann-Whitney, or Wilcoxon when alternating)")
+	runCmd.Flags().Float64Var(&minEffect, "min-effect", stats.DefaultMinEffect, "Minimum |Cliff's delta| for a difference to count as real")
+	runCmd.Flags().BoolVar(&adaptive, "adaptive", false, "Keep sampling in batches until the gain is resolved (replaces --runs)")
+	runCmd.Flags().IntVar(&minRuns, "min-runs", 6, "Adaptive mode: minimum runs per scenario")
+	runCmd.Flags().IntVar(&maxRuns, "max-runs", 50, "Adaptive mode: maximum runs per scenario")
+	runCmd.Flags().IntVar(&batchSize, "batch-size", 2, "Adaptive mode: runs per scenario added in each batch")
+	runCmd.Flags().Float64Var(&targetCIWidth, "target-ci-width", 2.0, "Adaptive mode: stop once the gain CI is narrower than this (percentage points)")
+	runCmd.Flags().DurationVar(&budget, "budget", 0, "Adaptive mode: wall-clock budget for the measurement phase, e.g. 10m (0 = unlimited)")
+	runCmd.Flags().Float64Var(&verdictThreshold, "verdict-threshold", report.DefaultVerdictThreshold, "Minimum gain % on a key metric to count as an improvement or regression")
+}
 
-	runCmd.MarkFlagRequired("baseline")
-	runCmd.MarkFlagRequired("optimized")
+// suiteOutput is what a finished suite leaves behind.
+type suiteOutput struct {
+	report   report.Report
+	jsonPath string
+	htmlPath string
 }
 
 func runBenchmark(cmd *cobra.Command, args []string) error {
 	bold := color.New(color.Bold)
 	green := color.New(color.FgGreen, color.Bold)
-	yellow := color.New(color.FgYellow)
 	red := color.New(color.FgRed)
 
 	bold.Println("\n╔══════════════════════════════════════════════════════════════╗")
 	bold.Println("║              ProcessGain - Performance Measurement           ║")
 	bold.Println("╚══════════════════════════════════════════════════════════════╝")
 
-	// Validate scripts exist
-	if _, err := os.Stat(baselineScript); os.IsNotExist(err) {
-		return fmt.Errorf("baseline script not found: %s", baselineScript)
-	}
-	if _, err := os.Stat(optimizedScript); os.IsNotExist(err) {
-		return fmt.Errorf("optimized script not found: %s", optimizedScript)
-	}
-
 	// Get machine info
 	machine := machineName
 	if machine == "" {
@@ -87,166 +170,469 @@ func runBenchmark(cmd *cobra.Command, args []string) error {
 		machine = hostname
 	}
 
+	// The first Ctrl-C stops the running scenario like a timeout and ends
+	// the benchmark; a second one exits at once
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if specFile == "" && (baselineScript == "" || (optimizedScript == "" && len(candidateFlags) == 0)) {
+		return fmt.Errorf("--baseline and --optimized (or --candidate) are required without --file")
+	}
+	// Errors from here on come from the suites, not from the command line
+	cmd.SilenceUsage = true
+
+	if specFile == "" {
+		cfg, err := flagConfig()
+		if err != nil {
+			return err
+		}
+		out, err := runSuite(ctx, cfg, machine)
+		if err != nil {
+			return err
+		}
+		if !out.report.Valid() {
+			return errInvalid
+		}
+		fmt.Println("\n" + green.Sprint("✓ Benchmark complete!"))
+		return nil
+	}
+
+	sp, err := spec.Load(specFile)
+	if err != nil {
+		return err
+	}
+
+	summary := report.SummaryIndex{
+		Version:     "1.0",
+		GeneratedAt: time.Now().UTC(),
+		Machine:     machine,
+		SpecFile:    sp.Path,
+	}
+	failed := 0
+	for i, s := range sp.Suites {
+		if ctx.Err() != nil {
+			break
+		}
+		fmt.Println("\n" + bold.Sprintf("▶ Suite %d/%d: %s", i+1, len(sp.Suites), s.Name))
+
+		entry := report.SuiteSummary{Name: s.Name}
+		out, err := runSuiteSpec(ctx, s, sp.Path, cmd.Flags(), machine)
+		if err != nil {
+			red.Printf("   ✗ Suite %s failed: %v\n", s.Name, err)
+			entry.Error = err.Error()
+			failed++
+		} else {
+			entry.JSONReport = filepath.Base(out.jsonPath)
+			entry.HTMLReport = filepath.Base(out.htmlPath)
+			entry.Metric = out.report.Metric
+			entry.GainPercent = out.report.Comparison.GainPercent
+			entry.Conclusive = out.report.Comparison.Conclusive
+			entry.Verdict = out.report.Verdict.Outcome
+			if !out.report.Valid() {
+				entry.Error = errInvalid.Error()
+				failed++
+			}
+		}
+		summary.Suites = append(summary.Suites, entry)
+	}
+
+	fmt.Println("\n📄 Generating summary...")
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output dir: %w", err)
+	}
+	base := reportBaseName("summary", machine, "")
+	jsonPath := filepath.Join(outputDir, base+".json")
+	jsonData, _ := json.MarshalIndent(summary, "", "  ")
+	if err := os.WriteFile(jsonPath, jsonData, 0644); err != nil {
+		return fmt.Errorf("failed to write JSON summary: %w", err)
+	}
+	fmt.Printf("   ✓ JSON: %s\n", jsonPath)
+
+	htmlPath := filepath.Join(outputDir, base+".html")
+	if err := report.GenerateSummaryHTML(summary, htmlPath); err != nil {
+		return fmt.Errorf("failed to write HTML summary: %w", err)
+	}
+	fmt.Printf("   ✓ HTML: %s\n", htmlPath)
+
+	if ctx.Err() != nil {
+		return errInterrupted
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d suites failed", failed, len(sp.Suites))
+	}
+	fmt.Println("\n" + green.Sprint("✓ Benchmark complete!"))
+	return nil
+}
+
+// runSuiteSpec resolves the configuration of a spec suite and runs it.
+func runSuiteSpec(ctx context.Context, s spec.Suite, specPath string, flags *pflag.FlagSet, machine string) (*suiteOutput, error) {
+	cfg, err := suiteConfig(s, specPath, flags)
+	if err != nil {
+		return nil, err
+	}
+	return runSuite(ctx, cfg, machine)
+}
+
+// reportBaseName names a report file after the machine, the suite (if any)
+// and the current time.
+func reportBaseName(kind, machine, suite string) string {
+	name := kind + "_" + machine
+	if suite != "" {
+		name += "_" + sanitizeName(suite)
+	}
+	return name + "_" + time.Now().Format("20060102_150405")
+}
+
+// sanitizeName keeps a suite name safe for use in a file name.
+func sanitizeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// errInterrupted is returned once Ctrl-C stopped the benchmark.
+var errInterrupted = errors.New("interrupted")
+
+// errInvalid is returned for a report that failed the output verification.
+var errInvalid = errors.New("output verification failed: report marked invalid")
+
+// runSuite measures one baseline/optimized comparison described by cfg and
+// writes its JSON and HTML reports. Cancelling ctx stops the running scenario
+// and abandons the suite.
+func runSuite(ctx context.Context, cfg report.Config, machine string) (*suiteOutput, error) {
+	bold := color.New(color.Bold)
+	green := color.New(color.FgGreen, color.Bold)
+	yellow := color.New(color.FgYellow)
+	red := color.New(color.FgRed)
+
+	if cfg.Adaptive && (cfg.MinRuns < 2 || cfg.MaxRuns < cfg.MinRuns || cfg.BatchSize < 1) {
+		return nil, fmt.Errorf("adaptive mode needs 2 <= --min-runs <= --max-runs and --batch-size >= 1")
+	}
+	if cfg.Order == "" {
+		cfg.Order = OrderRoundRobin
+	}
+	switch cfg.Order {
+	case OrderRoundRobin, OrderABBA, OrderRandom:
+	default:
+		return nil, fmt.Errorf("unknown order %q (expected %s, %s or %s)", cfg.Order, OrderRoundRobin, OrderABBA, OrderRandom)
+	}
+
+	limits := cgroup.Limits{CPUMax: cfg.CPUMax, CPUSetCPUs: cfg.CPUSetCPUs, MemoryMax: cfg.MemoryMax}
+	if cfg.CgroupMode == "" && !limits.Empty() {
+		cfg.CgroupMode = CgroupScenario
+	}
+	switch cfg.CgroupMode {
+	case "", CgroupScenario, CgroupRun:
+	default:
+		return nil, fmt.Errorf("unknown cgroup mode %q (expected %s or %s)", cfg.CgroupMode, CgroupScenario, CgroupRun)
+	}
+
+	sched, err := schedulingOf(&cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// The reports are named now, so that the artifacts written during the
+	// runs can sit next to them
+	base := reportBaseName("report", machine, cfg.Suite)
+	var artifactOpts executor.ArtifactOptions
+	if cfg.Artifacts {
+		if artifactOpts.MaxBytes, err = executor.ParseSize(cfg.ArtifactMaxSize); err != nil {
+			return nil, fmt.Errorf("invalid --artifact-max-size: %w", err)
+		}
+		if artifactOpts.MaxBytes == 0 {
+			cfg.ArtifactMaxSize = ""
+		}
+		artifactOpts.Gzip = cfg.ArtifactGzip
+		cfg.ArtifactDir = base + "_artifacts"
+	}
+
+	var checker *verify.Checker
+	if cfg.Verify != "" || cfg.VerifyCommand != "" {
+		if cfg.Verify == "" {
+			cfg.Verify = verify.SourceStdout
+		}
+		if checker, err = verify.New(cfg.Verify, cfg.VerifyCommand, cfg.VerifyChecksum, baselineName); err != nil {
+			return nil, err
+		}
+		defer checker.Close()
+	}
+
+	candidates := candidatesOf(cfg)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no candidate to compare against the baseline")
+	}
+
+	// Validate scripts exist
+	if _, err := os.Stat(cfg.BaselineScript); os.IsNotExist(err) {
+		return nil, fmt.Errorf("baseline script not found: %s", cfg.BaselineScript)
+	}
+	seen := map[string]bool{baselineName: true}
+	for _, c := range candidates {
+		if seen[c.Name] {
+			return nil, fmt.Errorf("duplicate scenario name %q", c.Name)
+		}
+		seen[c.Name] = true
+		if _, err := os.Stat(c.Script); os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s script not found: %s", c.Name, c.Script)
+		}
+	}
+
+	baseline := &scenarioSamples{name: baselineName, label: "Baseline", script: cfg.BaselineScript}
+	scenarios := []*scenarioSamples{baseline}
+	for _, c := range candidates {
+		label := c.Name
+		if c.Name == optimizedName {
+			label = "Optimized"
+		}
+		scenarios = append(scenarios, &scenarioSamples{name: c.Name, label: label, script: c.Script})
+	}
+	for name := range cfg.ScenarioHooks {
+		if !seen[name] {
+			return nil, fmt.Errorf("hooks given for unknown scenario %q", name)
+		}
+	}
+	for _, s := range scenarios {
+		s.hooks = cfg.Hooks.Merge(cfg.ScenarioHooks[s.name])
+	}
+
 	fmt.Printf("\n📊 Configuration:\n")
 	fmt.Printf("   Machine:    %s\n", machine)
-	fmt.Printf("   Baseline:   %s\n", baselineScript)
-	fmt.Printf("   Optimized:  %s\n", optimizedScript)
-	fmt.Printf("   Mode:       %s\n", mode)
-	fmt.Printf("   Warmup:     %d runs\n", warmupRuns)
-	fmt.Printf("   Measured:   %d runs per scenario\n", runs)
-	fmt.Printf("   Alternate:  %v\n", alternate)
-	fmt.Printf("   Cooldown:   %d ms\n", cooldownMs)
-	if tag != "" {
-		fmt.Printf("   Tag:        %s\n", tag)
-	}
-
-	// Check eBPF availability
-	ebpfCollector := ebpf.NewCollector()
-	ebpfAvailable := !noEbpf && ebpfCollector.IsAvailable()
-	if ebpfAvailable {
-		green.Println("\n✓ eBPF collection enabled (running as root)")
+	for _, s := range scenarios {
+		fmt.Printf("   %-11s %s\n", s.label+":", s.script)
+	}
+	fmt.Printf("   Mode:       %s\n", cfg.Mode)
+	fmt.Printf("   Warmup:     %d runs\n", cfg.WarmupRuns)
+	if cfg.Adaptive {
+		fmt.Printf("   Measured:   adaptive, %d-%d runs per scenario\n", cfg.MinRuns, cfg.MaxRuns)
 	} else {
-		yellow.Println("\n⚠ eBPF collection disabled (no root or --no-ebpf)")
+		fmt.Printf("   Measured:   %d runs per scenario\n", cfg.MeasuredRuns)
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	if cfg.Alternate {
+		fmt.Printf("   Alternate:  true (%s, seed %d)\n", cfg.Order, cfg.Seed)
+	} else {
+		fmt.Printf("   Alternate:  false\n")
+	}
+	fmt.Printf("   Cooldown:   %d ms\n", cfg.CooldownMs)
+	if !sched.IsZero() {
+		fmt.Printf("   Scheduling: %s\n", formatScheduling(cfg))
+	}
+	if cfg.Artifacts {
+		fmt.Printf("   Artifacts:  %s (%s)\n", filepath.Join(outputDir, cfg.ArtifactDir), formatArtifacts(cfg))
+	}
+	if checker != nil {
+		fmt.Printf("   Verify:     %s\n", checker.Method())
+	}
+	if hooks := formatHooks(scenarios); hooks != "" {
+		fmt.Printf("   Hooks:      %s\n", hooks)
+	}
+	if len(cfg.Tags) > 0 {
+		fmt.Printf("   Tag:        %s\n", strings.Join(cfg.Tags, ", "))
 	}
 
-	exec := executor.New(timeout, cooldownMs, envFile)
+	collectors, err := setupCollectors(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer collectors.Close()
 
-	// Warmup phase
-	if warmupRuns > 0 {
-		fmt.Printf("\n🔥 Warmup phase (%d runs each)...\n", warmupRuns)
-		for i := 0; i < warmupRuns; i++ {
-			fmt.Printf("   Warmup baseline %d/%d...", i+1, warmupRuns)
-			_, err := exec.Run(baselineScript, mode)
-			if err != nil {
-				red.Printf(" FAILED: %v\n", err)
-			} else {
-				fmt.Println(" done")
-			}
+	// Run the scenarios in their own cgroups when asked to, or to scope the
+	// collectors that can filter by cgroup
+	if cfg.CgroupMode != "" {
+		if err := createCgroups(scenarios, limits, cfg.CgroupMode == CgroupRun); err != nil {
+			return nil, fmt.Errorf("failed to set up cgroups: %w", err)
+		}
+		defer removeCgroups(scenarios)
+		green.Printf("✓ Cgroup per %s%s\n", cfg.CgroupMode, formatLimits(limits))
+	} else if collectors.WantsCgroup() {
+		if err := createCgroups(scenarios, limits, false); err != nil {
+			yellow.Printf("⚠ Per-scenario cgroups unavailable (%v); collectors cover the whole system\n", err)
+		} else {
+			defer removeCgroups(scenarios)
+		}
+	}
 
-			fmt.Printf("   Warmup optimized %d/%d...", i+1, warmupRuns)
-			_, err = exec.Run(optimizedScript, mode)
-			if err != nil {
-				red.Printf(" FAILED: %v\n", err)
-			} else {
-				fmt.Println(" done")
+	exec := executor.New(cfg.Timeout, cfg.CooldownMs, cfg.EnvFile)
+	exec.AddEnv(cfg.Env)
+	exec.Sched = sched
+	exec.Context = ctx
+	exec.Artifacts = artifactOpts
+	exec.KeepOutput = checker != nil && checker.File() == ""
+	m := &measurer{
+		exec:       exec,
+		mode:       cfg.Mode,
+		collectors: collectors,
+		order:      cfg.Order,
+		rng:        rand.New(rand.NewSource(cfg.Seed)),
+		runCgroups: cfg.CgroupMode == CgroupRun,
+		limits:     limits,
+		verifier:   checker,
+	}
+	if cfg.Artifacts {
+		m.artifactDir = filepath.Join(outputDir, cfg.ArtifactDir)
+	}
+
+	if err := m.setup(scenarios); err != nil {
+		return nil, err
+	}
+	defer m.teardown(scenarios)
+
+	// Warmup phase
+	if cfg.WarmupRuns > 0 {
+		fmt.Printf("\n🔥 Warmup phase (%d runs each)...\n", cfg.WarmupRuns)
+		for i := 0; i < cfg.WarmupRuns && ctx.Err() == nil; i++ {
+			for _, s := range scenarios {
+				if ctx.Err() != nil {
+					break
+				}
+				fmt.Printf("   Warmup %s %d/%d...", strings.ToLower(s.label), i+1, cfg.WarmupRuns)
+				err := m.warmup(s)
+				if err != nil {
+					red.Printf(" FAILED: %v\n", err)
+				} else {
+					fmt.Println(" done")
+				}
 			}
 		}
 	}
 
 	// Measurement phase
-	fmt.Printf("\n📏 Measurement phase (%d runs each)...\n", runs)
-
-	var baselineResults []executor.RunResult
-	var optimizedResults []executor.RunResult
-	var baselineEbpf []ebpf.Metrics
-	var optimizedEbpf []ebpf.Metrics
-
-	if alternate {
-		// A/B/A/B alternation
-		for i := 0; i < runs; i++ {
-			// Baseline run
-			fmt.Printf("   [%d/%d] Baseline...", i+1, runs)
-			var ebpfMetrics *ebpf.Metrics
-			if ebpfAvailable {
-				ebpfCollector.Start()
-			}
-			result, err := exec.Run(baselineScript, mode)
-			if ebpfAvailable {
-				ebpfMetrics = ebpfCollector.Stop()
-				baselineEbpf = append(baselineEbpf, *ebpfMetrics)
-			}
-			if err != nil {
-				red.Printf(" FAILED: %v\n", err)
-				result.Error = err.Error()
-			} else {
-				fmt.Printf(" %.2fms\n", result.DurationMs)
-			}
-			baselineResults = append(baselineResults, result)
+	exec.OnStart = collectors.Watch
 
-			// Optimized run
-			fmt.Printf("   [%d/%d] Optimized...", i+1, runs)
-			if ebpfAvailable {
-				ebpfCollector.Start()
-			}
-			result, err = exec.Run(optimizedScript, mode)
-			if ebpfAvailable {
-				ebpfMetrics = ebpfCollector.Stop()
-				optimizedEbpf = append(optimizedEbpf, *ebpfMetrics)
-			}
-			if err != nil {
-				red.Printf(" FAILED: %v\n", err)
-				result.Error = err.Error()
-			} else {
-				fmt.Printf(" %.2fms\n", result.DurationMs)
-			}
-			optimizedResults = append(optimizedResults, result)
+	metric := metricForMode(cfg.Mode)
+	compareOpts := stats.Options{
+		Alternate:  cfg.Alternate,
+		Confidence: cfg.Confidence,
+		Resamples:  cfg.Resamples,
+		Seed:       cfg.Seed,
+		Alpha:      cfg.Alpha,
+		MinEffect:  cfg.MinEffect,
+	}
+	// compareAll compares every candidate with the baseline on the primary
+	// metric, corrected for multiple comparisons.
+	compareAll := func() []stats.Comparison {
+		comps := make([]stats.Comparison, len(candidates))
+		for i, s := range scenarios[1:] {
+			comps[i] = metric.compare(baseline.results, s.results, compareOpts)
 		}
+		stats.AdjustMultiple(comps, metric.options(compareOpts))
+		return comps
+	}
+
+	var sampling report.Sampling
+	if cfg.Adaptive {
+		fmt.Printf("\n📏 Adaptive measurement phase (%d-%d runs each, batches of %d)...\n", cfg.MinRuns, cfg.MaxRuns, cfg.BatchSize)
+		sampling = measureAdaptive(m, cfg, scenarios, compareAll)
 	} else {
-		// Sequential: all baseline then all optimized
-		for i := 0; i < runs; i++ {
-			fmt.Printf("   Baseline [%d/%d]...", i+1, runs)
-			var ebpfMetrics *ebpf.Metrics
-			if ebpfAvailable {
-				ebpfCollector.Start()
-			}
-			result, err := exec.Run(baselineScript, mode)
-			if ebpfAvailable {
-				ebpfMetrics = ebpfCollector.Stop()
-				baselineEbpf = append(baselineEbpf, *ebpfMetrics)
-			}
-			if err != nil {
-				red.Printf(" FAILED: %v\n", err)
-				result.Error = err.Error()
-			} else {
-				fmt.Printf(" %.2fms\n", result.DurationMs)
-			}
-			baselineResults = append(baselineResults, result)
-		}
-		for i := 0; i < runs; i++ {
-			fmt.Printf("   Optimized [%d/%d]...", i+1, runs)
-			var ebpfMetrics *ebpf.Metrics
-			if ebpfAvailable {
-				ebpfCollector.Start()
-			}
-			result, err := exec.Run(optimizedScript, mode)
-			if ebpfAvailable {
-				ebpfMetrics = ebpfCollector.Stop()
-				optimizedEbpf = append(optimizedEbpf, *ebpfMetrics)
-			}
-			if err != nil {
-				red.Printf(" FAILED: %v\n", err)
-				result.Error = err.Error()
-			} else {
-				fmt.Printf(" %.2fms\n", result.DurationMs)
-			}
-			optimizedResults = append(optimizedResults, result)
+		fmt.Printf("\n📏 Measurement phase (%d runs each)...\n", cfg.MeasuredRuns)
+		measureStart := time.Now()
+		m.measureBatch(scenarios, cfg.MeasuredRuns, 0, cfg.Alternate, func(run int) string {
+			return fmt.Sprintf("[%d/%d]", run, cfg.MeasuredRuns)
+		})
+		sampling = report.Sampling{
+			Runs:       cfg.MeasuredRuns,
+			Batches:    1,
+			StopReason: report.StopFixedRuns,
+			ElapsedSec: time.Since(measureStart).Seconds(),
 		}
 	}
+	sampling.ExecutionOrder = m.executed
+	m.teardown(scenarios)
+	if ctx.Err() != nil {
+		return nil, errInterrupted
+	}
 
 	// Calculate statistics
 	fmt.Println("\n📈 Calculating statistics...")
 
-	baselineDurations := extractDurations(baselineResults)
-	optimizedDurations := extractDurations(optimizedResults)
+	baselineValues := metric.extract(baseline.results)
+	baselineStats := stats.Calculate(baselineValues)
+
+	comparisons := compareAll()
+	ranked := make([]report.CandidateResult, len(candidates))
+	for i, s := range scenarios[1:] {
+		values := metric.extract(s.results)
+		var metrics []report.MetricResult
+		for j, mm := range metricsForMode(cfg.Mode, baseline.results, s.results) {
+			mr := mm.summarize(baseline.results, s.results, compareOpts)
+			// A metric only one scenario recorded has nothing to compare to
+			if j > 0 && mr.Comparison.Missing {
+				continue
+			}
+			metrics = append(metrics, mr)
+		}
+		// The primary metric comes first; keep its corrected comparison
+		metrics[0].Comparison = comparisons[i]
+
+		ranked[i] = report.CandidateResult{
+			Name:   candidates[i].Name,
+			Script: candidates[i].Script,
+			Scenario: report.ScenarioResult{
+				Runs:      s.results,
+				Values:    values,
+				Stats:     stats.Calculate(values),
+				Ebpf:      s.ebpf,
+				Collected: s.collected,
+				Setup:     s.setupHook,
+				Teardown:  s.teardownHook,
+			},
+			Comparison: comparisons[i],
+			Metrics:    metrics,
+			Verdict:    report.ParetoVerdict(metrics, cfg.VerdictThreshold),
+			Ebpf:       ebpf.Compare(baseline.ebpf, s.ebpf),
+			Sections:   collect.Compare(cfg.Collect, baseline.collected, s.collected),
+		}
+		sampling.CIWidth = math.Max(sampling.CIWidth, comparisons[i].GainCIHigh-comparisons[i].GainCILow)
+	}
+	sort.SliceStable(ranked, func(a, b int) bool {
+		ca, cb := ranked[a].Comparison, ranked[b].Comparison
+		if ca.HasGain() != cb.HasGain() {
+			return ca.HasGain()
+		}
+		return ca.GainPercent > cb.GainPercent
+	})
+	for i := range ranked {
+		ranked[i].Rank = i + 1
+	}
 
-	baselineStats := stats.Calculate(baselineDurations)
-	optimizedStats := stats.Calculate(optimizedDurations)
-	comparison := stats.Compare(baselineDurations, optimizedDurations, alternate)
+	// The top-ranked candidate is the headline comparison
+	best := ranked[0]
+	optimizedStats := best.Scenario.Stats
+	comparison := best.Comparison
+	metrics := best.Metrics
+	verdict := best.Verdict
 
 	// Display results
 	fmt.Println("\n" + bold.Sprint("═══════════════════════════════════════════════════════════════"))
 	fmt.Println(bold.Sprint("                         RESULTS"))
 	fmt.Println(bold.Sprint("═══════════════════════════════════════════════════════════════"))
 
+	if len(ranked) > 1 {
+		fmt.Println("\n" + bold.Sprintf("Ranking vs baseline (median %.2f %s, p adjusted with %s):", baselineStats.Median, metric.unit, stats.CorrectionHolm))
+		displayRanking(ranked, metric.unit)
+		fmt.Println("\n" + bold.Sprintf("Best candidate: %s", best.Name))
+	}
+
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetHeader([]string{"Metric", "Baseline", "Optimized"})
 	table.SetBorder(false)
-	table.Append([]string{"Median (ms)", fmt.Sprintf("%.2f", baselineStats.Median), fmt.Sprintf("%.2f", optimizedStats.Median)})
-	table.Append([]string{"Mean (ms)", fmt.Sprintf("%.2f", baselineStats.Mean), fmt.Sprintf("%.2f", optimizedStats.Mean)})
-	table.Append([]string{"Std Dev (ms)", fmt.Sprintf("%.2f", baselineStats.StdDev), fmt.Sprintf("%.2f", optimizedStats.StdDev)})
+	table.Append([]string{fmt.Sprintf("Median (%s)", metric.unit), fmt.Sprintf("%.2f", baselineStats.Median), fmt.Sprintf("%.2f", optimizedStats.Median)})
+	table.Append([]string{fmt.Sprintf("Mean (%s)", metric.unit), fmt.Sprintf("%.2f", baselineStats.Mean), fmt.Sprintf("%.2f", optimizedStats.Mean)})
+	table.Append([]string{fmt.Sprintf("Std Dev (%s)", metric.unit), fmt.Sprintf("%.2f", baselineStats.StdDev), fmt.Sprintf("%.2f", optimizedStats.StdDev)})
 	table.Append([]string{"CV (%)", fmt.Sprintf("%.2f", baselineStats.CV), fmt.Sprintf("%.2f", optimizedStats.CV)})
-	table.Append([]string{"P10 (ms)", fmt.Sprintf("%.2f", baselineStats.P10), fmt.Sprintf("%.2f", optimizedStats.P10)})
-	table.Append([]string{"P90 (ms)", fmt.Sprintf("%.2f", baselineStats.P90), fmt.Sprintf("%.2f", optimizedStats.P90)})
+	table.Append([]string{fmt.Sprintf("P10 (%s)", metric.unit), fmt.Sprintf("%.2f", baselineStats.P10), fmt.Sprintf("%.2f", optimizedStats.P10)})
+	table.Append([]string{fmt.Sprintf("P90 (%s)", metric.unit), fmt.Sprintf("%.2f", baselineStats.P90), fmt.Sprintf("%.2f", optimizedStats.P90)})
 	table.Render()
 
 	fmt.Println()
@@ -258,124 +644,388 @@ func runBenchmark(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Printf("🎯 ")
-	gainColor.Printf("GAIN: %.2f%%", comparison.GainPercent)
-	fmt.Printf(" (median baseline %.2fms → optimized %.2fms)\n", baselineStats.Median, optimizedStats.Median)
-	fmt.Printf("   P10/P90 of gain: %.2f%% / %.2f%%\n", comparison.GainP10, comparison.GainP90)
+	switch {
+	case comparison.Missing:
+		red.Printf("GAIN: n/a")
+		fmt.Printf(" (no successful run of %s to compare)\n", missingScenario(baselineStats, best.Name))
+	case comparison.GainUndefined:
+		yellow.Printf("GAIN: n/a")
+		fmt.Printf(" (a baseline run is ≤ 0: no percentage of it; median baseline %.2f %s → %s %.2f %s)\n",
+			baselineStats.Median, metric.unit, best.Name, optimizedStats.Median, metric.unit)
+	default:
+		gainColor.Printf("GAIN: %.2f%%", comparison.GainPercent)
+		fmt.Printf(" (median baseline %.2f %s → %s %.2f %s)\n",
+			baselineStats.Median, metric.unit, best.Name, optimizedStats.Median, metric.unit)
+		fmt.Printf("   %.0f%% CI of gain (%s bootstrap): [%.2f%%, %.2f%%]\n",
+			comparison.Confidence*100, comparison.CIMethod, comparison.GainCILow, comparison.GainCIHigh)
+		fmt.Printf("   P10/P90 of gain: %.2f%% / %.2f%%\n", comparison.GainP10, comparison.GainP90)
+	}
+
+	if !comparison.Missing {
+		fmt.Printf("   %s: p=%.4f | Cliff's delta: %.2f | Hodges-Lehmann shift: %+.2f %s\n",
+			comparison.Test, comparison.PValue, comparison.CliffsDelta, comparison.HodgesLehmann, metric.unit)
+	}
+	if comparison.Correction != "" {
+		fmt.Printf("   Adjusted p (%s, %d candidates): %.4f\n", comparison.Correction, len(ranked), comparison.AdjustedP)
+	}
+
+	if sampling.Adaptive {
+		fmt.Printf("   Sampling stopped after %d runs per scenario: %s\n", sampling.Runs, sampling.StopReason)
+	}
 
 	if comparison.Conclusive {
-		green.Println("   ✓ Result is CONCLUSIVE (low overlap, stable measurements)")
+		green.Printf("   ✓ Result is CONCLUSIVE (p < %g, |delta| ≥ %g, CI excludes 0)\n", cfg.Alpha, cfg.MinEffect)
 	} else {
-		yellow.Println("   ⚠ Result is INCONCLUSIVE (high variance or overlap)")
+		yellow.Printf("   ⚠ Result is INCONCLUSIVE (needs p < %g, |delta| ≥ %g and a CI excluding 0)\n", cfg.Alpha, cfg.MinEffect)
+	}
+
+	fmt.Println("\n" + bold.Sprint("All metrics (median per run, * = key metric):"))
+	displayMetricComparison(metrics)
+
+	verdictColor := yellow
+	switch verdict.Outcome {
+	case report.OutcomeBetter:
+		verdictColor = green
+	case report.OutcomeWorse:
+		verdictColor = red
 	}
+	fmt.Printf("\n⚖  ")
+	verdictColor.Printf("VERDICT: %s\n", strings.ToUpper(verdict.Outcome))
+	fmt.Printf("   %s\n", verdict.Summary)
 
 	// eBPF summary if available
-	if ebpfAvailable && len(baselineEbpf) > 0 {
-		fmt.Println("\n" + bold.Sprint("eBPF Insights:"))
-		displayEbpfComparison(baselineEbpf, optimizedEbpf)
+	if len(baseline.ebpf) > 0 {
+		agg := ebpf.Aggregate(baseline.ebpf)
+		title, scope := "eBPF Insights", "whole system"
+		if agg.Source == ebpf.SourceProc {
+			title = "Process Insights (/proc)"
+		}
+		if agg.Scope != ebpf.ScopeSystem {
+			scope = "scenario process trees"
+		}
+		fmt.Println("\n" + bold.Sprintf("%s (%s):", title, scope))
+		displayEbpfComparison(best.Ebpf)
+	}
+	for _, section := range best.Sections {
+		fmt.Println("\n" + bold.Sprintf("%s:", section.Title))
+		displaySection(section)
+	}
+	var verification *verify.Summary
+	if checker != nil {
+		names := make([]string, len(scenarios))
+		for i, s := range scenarios {
+			names[i] = s.name
+		}
+		verification = checker.Summary(names)
+		displayVerification(verification)
 	}
 
 	// Generate report
 	fmt.Println("\n📄 Generating reports...")
 
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output dir: %w", err)
+		return nil, fmt.Errorf("failed to create output dir: %w", err)
 	}
 
+	cfg.MeasuredRuns = sampling.Runs
 	reportData := report.Report{
 		Version:     "1.0",
 		GeneratedAt: time.Now().UTC(),
 		Machine:     machine,
-		Tag:         tag,
-		Config: report.Config{
-			BaselineScript:  baselineScript,
-			OptimizedScript: optimizedScript,
-			Mode:            mode,
-			WarmupRuns:      warmupRuns,
-			MeasuredRuns:    runs,
-			Alternate:       alternate,
-			CooldownMs:      cooldownMs,
-			Timeout:         timeout,
-		},
+		Tag:         strings.Join(cfg.Tags, ","),
+		Metric:      metric.name,
+		Unit:        metric.unit,
+		Config:      cfg,
 		Baseline: report.ScenarioResult{
-			Runs:  baselineResults,
-			Stats: baselineStats,
-			Ebpf:  baselineEbpf,
-		},
-		Optimized: report.ScenarioResult{
-			Runs:  optimizedResults,
-			Stats: optimizedStats,
-			Ebpf:  optimizedEbpf,
+			Runs:      baseline.results,
+			Values:    baselineValues,
+			Stats:     baselineStats,
+			Ebpf:      baseline.ebpf,
+			Collected: baseline.collected,
+			Setup:     baseline.setupHook,
+			Teardown:  baseline.teardownHook,
 		},
+		Optimized:  best.Scenario,
 		Comparison: comparison,
+		Sampling:   sampling,
+		Metrics:    metrics,
+		Verdict:    verdict,
+		Ebpf:       best.Ebpf,
+		Sections:   best.Sections,
+	}
+	if len(ranked) > 1 {
+		reportData.Candidates = ranked
 	}
+	reportData.Verification = verification
 
 	// Write JSON report
-	jsonPath := filepath.Join(outputDir, fmt.Sprintf("report_%s_%s.json", machine, time.Now().Format("20060102_150405")))
+	jsonPath := filepath.Join(outputDir, base+".json")
 	jsonData, _ := json.MarshalIndent(reportData, "", "  ")
 	if err := os.WriteFile(jsonPath, jsonData, 0644); err != nil {
-		return fmt.Errorf("failed to write JSON report: %w", err)
+		return nil, fmt.Errorf("failed to write JSON report: %w", err)
 	}
 	fmt.Printf("   ✓ JSON: %s\n", jsonPath)
 
 	// Write HTML report
-	htmlPath := filepath.Join(outputDir, fmt.Sprintf("report_%s_%s.html", machine, time.Now().Format("20060102_150405")))
+	htmlPath := filepath.Join(outputDir, base+".html")
 	if err := report.GenerateHTML(reportData, htmlPath); err != nil {
-		return fmt.Errorf("failed to write HTML report: %w", err)
+		return nil, fmt.Errorf("failed to write HTML report: %w", err)
 	}
 	fmt.Printf("   ✓ HTML: %s\n", htmlPath)
 
-	fmt.Println("\n" + green.Sprint("✓ Benchmark complete!"))
+	return &suiteOutput{report: reportData, jsonPath: jsonPath, htmlPath: htmlPath}, nil
+}
 
-	return nil
+// displayRanking prints the candidates of an N-way comparison, best first.
+func displayRanking(ranked []report.CandidateResult, unit string) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Rank", "Scenario", fmt.Sprintf("Median (%s)", unit), "Gain %", "CI %", "p (adj)", "Cliff's δ", "Verdict"})
+	table.SetBorder(false)
+	for _, c := range ranked {
+		comp := c.Comparison
+		p := comp.PValue
+		if comp.Correction != "" {
+			p = comp.AdjustedP
+		}
+		name := c.Name
+		if comp.Conclusive {
+			name += " ✓"
+		}
+		table.Append([]string{
+			fmt.Sprintf("%d", c.Rank),
+			name,
+			fmt.Sprintf("%.2f", c.Scenario.Stats.Median),
+			formatGain("%+.2f", comp),
+			formatGainCI("[%.2f, %.2f]", comp),
+			formatP("%.4f", comp, p),
+			fmt.Sprintf("%.2f", comp.CliffsDelta),
+			strings.ToUpper(c.Verdict.Outcome),
+		})
+	}
+	table.Render()
+}
+
+// formatGain formats the gain of a comparison, or tells why it has none.
+func formatGain(format string, c stats.Comparison) string {
+	switch {
+	case c.Missing:
+		return "missing"
+	case c.GainUndefined:
+		return "n/a"
+	}
+	return fmt.Sprintf(format, c.GainPercent)
 }
 
-func extractDurations(results []executor.RunResult) []float64 {
-	durations := make([]float64, 0, len(results))
-	for _, r := range results {
-		if r.Error == "" {
-			durations = append(durations, r.DurationMs)
+// formatGainCI formats the CI of the gain, "-" for a comparison without a
+// gain.
+func formatGainCI(format string, c stats.Comparison) string {
+	if !c.HasGain() {
+		return "-"
+	}
+	return fmt.Sprintf(format, c.GainCILow, c.GainCIHigh)
+}
+
+// formatP formats a p-value of a comparison, "-" when nothing was compared.
+func formatP(format string, c stats.Comparison, p float64) string {
+	if c.Missing {
+		return "-"
+	}
+	return fmt.Sprintf(format, p)
+}
+
+// missingScenario names the scenario of a comparison without samples.
+func missingScenario(baseline stats.Stats, name string) string {
+	if baseline.Count == 0 {
+		return "the baseline"
+	}
+	return name
+}
+
+func displayMetricComparison(metrics []report.MetricResult) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Metric", "Baseline", "Optimized", "Gain %", "CI %", "p", "Conclusive"})
+	table.SetBorder(false)
+	for _, r := range metrics {
+		label := fmt.Sprintf("%s (%s)", r.Label, r.Unit)
+		if r.Key {
+			label = "* " + label
+		}
+		conclusive := "no"
+		if r.Comparison.Conclusive {
+			conclusive = "yes"
+		}
+		table.Append([]string{
+			label,
+			fmt.Sprintf("%.2f", r.Baseline.Median),
+			fmt.Sprintf("%.2f", r.Optimized.Median),
+			formatGain("%.2f", r.Comparison),
+			formatGainCI("[%.1f, %.1f]", r.Comparison),
+			formatP("%.3f", r.Comparison, r.Comparison.PValue),
+			conclusive,
+		})
+	}
+	table.Render()
+}
+
+func displayEbpfComparison(cmp *ebpf.Comparison) {
+	if cmp == nil {
+		return
+	}
+
+	for _, c := range cmp.Counters {
+		unit := c.Unit
+		if unit == "count" {
+			unit = ""
+		}
+		fmt.Printf("   %s: %.2f%s → %.2f%s\n", c.Name, c.Baseline, unit, c.Optimized, unit)
+	}
+	if len(cmp.OffCpuStacks) > 0 {
+		fmt.Println("   Off-CPU stacks with the largest change (mean per run):")
+		for i, s := range cmp.OffCpuStacks {
+			if i == 5 {
+				break
+			}
+			fmt.Printf("     %+9.2fms  %8.2fms → %8.2fms  %s\n",
+				s.DeltaMs, s.BaselineMs, s.OptimizedMs, ebpf.ShortStack(s.Stack, 3))
+		}
+	}
+	if len(cmp.Syscalls) > 0 {
+		fmt.Println("   Syscalls by impact (mean per run):")
+		displaySyscalls(cmp.Syscalls)
+	}
+}
+
+// displaySyscalls prints the syscall diff, largest latency change first.
+func displaySyscalls(syscalls []ebpf.SyscallDelta) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Syscall", "Count", "Δ Count", "Time (ms)", "Δ Time (ms)"})
+	table.SetBorder(false)
+	for i, s := range syscalls {
+		if i == 8 {
+			break
 		}
+		table.Append([]string{
+			s.Name,
+			fmt.Sprintf("%.0f → %.0f", s.BaselineCount, s.OptimizedCount),
+			fmt.Sprintf("%+.0f", s.CountDelta),
+			fmt.Sprintf("%.2f → %.2f", s.BaselineLatencyUs/1000, s.OptimizedLatencyUs/1000),
+			fmt.Sprintf("%+.2f", s.LatencyDeltaUs/1000),
+		})
 	}
-	return durations
+	table.Render()
 }
 
-func displayEbpfComparison(baseline, optimized []ebpf.Metrics) {
-	// Aggregate eBPF metrics
-	baselineAgg := ebpf.Aggregate(baseline)
-	optimizedAgg := ebpf.Aggregate(optimized)
+// formatLimits renders the cgroup limits for the console, "" when unlimited.
+func formatLimits(l cgroup.Limits) string {
+	var parts []string
+	if l.CPUMax != "" {
+		parts = append(parts, "cpu.max="+l.CPUMax)
+	}
+	if l.CPUSetCPUs != "" {
+		parts = append(parts, "cpuset.cpus="+l.CPUSetCPUs)
+	}
+	if l.MemoryMax != "" {
+		parts = append(parts, "memory.max="+l.MemoryMax)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
 
-	if baselineAgg.RunqueueLatencyUs > 0 || optimizedAgg.RunqueueLatencyUs > 0 {
-		fmt.Printf("   Runqueue latency (avg): %.2fμs → %.2fμs\n",
-			baselineAgg.RunqueueLatencyUs, optimizedAgg.RunqueueLatencyUs)
+// formatScheduling renders the applied CPU pinning and priorities.
+func formatScheduling(cfg report.Config) string {
+	var parts []string
+	if cfg.CPUs != "" {
+		parts = append(parts, "CPUs "+cfg.CPUs)
+	}
+	if cfg.Nice != 0 {
+		parts = append(parts, fmt.Sprintf("nice %d", cfg.Nice))
 	}
-	if baselineAgg.OffCpuTimeMs > 0 || optimizedAgg.OffCpuTimeMs > 0 {
-		fmt.Printf("   Off-CPU time (total): %.2fms → %.2fms\n",
-			baselineAgg.OffCpuTimeMs, optimizedAgg.OffCpuTimeMs)
+	if cfg.IONice != "" {
+		parts = append(parts, "ionice "+cfg.IONice)
 	}
-	if baselineAgg.IoLatencyUs > 0 || optimizedAgg.IoLatencyUs > 0 {
-		fmt.Printf("   I/O latency (avg): %.2fμs → %.2fμs\n",
-			baselineAgg.IoLatencyUs, optimizedAgg.IoLatencyUs)
+	return strings.Join(parts, ", ")
+}
+
+// formatHooks lists the hooks set for each scenario, or "" when there are
+// none.
+func formatHooks(scenarios []*scenarioSamples) string {
+	var parts []string
+	for _, s := range scenarios {
+		var set []string
+		for _, h := range []struct{ name, command string }{
+			{executor.HookSetup, s.hooks.Setup}, {executor.HookBeforeRun, s.hooks.BeforeRun},
+			{executor.HookAfterRun, s.hooks.AfterRun}, {executor.HookTeardown, s.hooks.Teardown},
+		} {
+			if h.command != "" {
+				set = append(set, h.name)
+			}
+		}
+		if len(set) > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %s", s.name, strings.Join(set, ", ")))
+		}
 	}
-	if len(baselineAgg.TopSyscalls) > 0 {
-		fmt.Println("   Top syscalls (baseline):", formatSyscalls(baselineAgg.TopSyscalls))
+	return strings.Join(parts, "; ")
+}
+
+// formatArtifacts renders the size cap and compression of the artifacts.
+func formatArtifacts(cfg report.Config) string {
+	s := "no size cap"
+	if cfg.ArtifactMaxSize != "" {
+		s = cfg.ArtifactMaxSize + " max per file"
 	}
-	if len(optimizedAgg.TopSyscalls) > 0 {
-		fmt.Println("   Top syscalls (optimized):", formatSyscalls(optimizedAgg.TopSyscalls))
+	if cfg.ArtifactGzip {
+		s += ", gzip"
 	}
+	return s
 }
 
-func formatSyscalls(syscalls map[string]int64) string {
-	result := ""
-	count := 0
-	for name, cnt := range syscalls {
-		if count > 0 {
-			result += ", "
+// displayVerification prints the outcome of the output check, with the
+// first difference of each scenario that failed it.
+func displayVerification(v *verify.Summary) {
+	bold := color.New(color.Bold)
+	green := color.New(color.FgGreen)
+	yellow := color.New(color.FgYellow)
+	red := color.New(color.FgRed)
+
+	fmt.Println("\n" + bold.Sprintf("Output verification (%s):", v.Method))
+	if v.Error != "" {
+		red.Printf("   ✗ %s\n", v.Error)
+	}
+	for _, r := range v.Scenarios {
+		reference := "the baseline"
+		if r.Scenario == baselineName {
+			reference = "its first run"
 		}
-		result += fmt.Sprintf("%s:%d", name, cnt)
-		count++
-		if count >= 5 {
-			break
+		switch {
+		case r.Mismatches > 0:
+			red.Printf("   ✗ %s: %d of %d runs differ from %s (first: run %d)\n", r.Scenario, r.Mismatches, r.Checked, reference, r.FirstMismatch)
+			for _, line := range strings.Split(strings.TrimRight(r.Excerpt, "\n"), "\n") {
+				fmt.Printf("       %s\n", line)
+			}
+		case r.Checked > 0:
+			green.Printf("   ✓ %s: %d runs match\n", r.Scenario, r.Checked)
+		default:
+			yellow.Printf("   ? %s: no successful run to check\n", r.Scenario)
+		}
+	}
+	if !v.Passed {
+		red.Println("   ⚠ Report marked INVALID: the gain compares different work")
+	}
+}
+
+// displaySection prints the values of one collector, baseline → optimized.
+func displaySection(section collect.Section) {
+	for _, r := range section.Rows {
+		c := color.New(color.Reset)
+		switch r.Outcome {
+		case "better":
+			c = color.New(color.FgGreen)
+		case "worse":
+			c = color.New(color.FgRed)
 		}
+		fmt.Printf("   %s: %.4g%s → %.4g%s ", r.Name, r.Baseline, r.Unit, r.Optimized, r.Unit)
+		c.Printf("(%+.1f%%)\n", r.DeltaPercent)
 	}
-	return result
 }
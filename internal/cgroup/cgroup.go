@@ -0,0 +1,199 @@
+package cgroup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Group is a cgroup v2 group created for a scenario or a single run.
+type Group struct {
+	// Path is the group's directory in the cgroup v2 hierarchy.
+	Path string
+	// ID is the cgroup ID, as returned by bpf_get_current_cgroup_id.
+	ID uint64
+}
+
+// Available reports whether groups can be created: cgroup v2 is mounted
+// and writable by this process.
+func Available() bool {
+	root, err := Mountpoint()
+	return err == nil && unix.Access(root, unix.W_OK) == nil
+}
+
+// Create makes the group at rel, relative to the cgroup v2 mountpoint.
+func Create(rel string) (*Group, error) {
+	root, err := Mountpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(root, rel)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+
+	id, err := inode(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return &Group{Path: path, ID: id}, nil
+}
+
+// Child creates the group name below g.
+func (g *Group) Child(name string) (*Group, error) {
+	path := filepath.Join(g.Path, name)
+	if err := os.Mkdir(path, 0755); err != nil {
+		return nil, err
+	}
+	id, err := inode(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return &Group{Path: path, ID: id}, nil
+}
+
+// Remove deletes the group, which fails while it still has processes.
+func (g *Group) Remove() error {
+	return os.Remove(g.Path)
+}
+
+// Accounted lists the controllers whose files Stats reads. They are enabled
+// for every group where the hierarchy has them, limits or not.
+var Accounted = []string{"cpu", "memory", "io"}
+
+// Limits are optional resource limits of a group, in the format of the
+// cgroup v2 interface files; empty fields are left unlimited.
+type Limits struct {
+	// CPUMax is "$QUOTA $PERIOD" in microseconds, e.g. "50000 100000"
+	CPUMax string
+	// CPUSetCPUs is a CPU list, e.g. "0-3,8"
+	CPUSetCPUs string
+	// MemoryMax is a size in bytes, with an optional K/M/G suffix
+	MemoryMax string
+}
+
+// Empty reports whether no limit is set.
+func (l Limits) Empty() bool {
+	return l.CPUMax == "" && l.CPUSetCPUs == "" && l.MemoryMax == ""
+}
+
+// Controllers lists the controllers the limits need.
+func (l Limits) Controllers() []string {
+	var controllers []string
+	if l.CPUMax != "" {
+		controllers = append(controllers, "cpu")
+	}
+	if l.CPUSetCPUs != "" {
+		controllers = append(controllers, "cpuset")
+	}
+	if l.MemoryMax != "" {
+		controllers = append(controllers, "memory")
+	}
+	return controllers
+}
+
+// Apply writes the limits to the group. The controllers must have been
+// delegated to it (see Delegate).
+func (g *Group) Apply(l Limits) error {
+	for _, w := range []struct{ file, value string }{
+		{"cpu.max", l.CPUMax},
+		{"cpuset.cpus", l.CPUSetCPUs},
+		{"memory.max", l.MemoryMax},
+	} {
+		if w.value == "" {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(g.Path, w.file), []byte(w.value), 0644); err != nil {
+			return fmt.Errorf("failed to set %s to %q: %w", w.file, w.value, err)
+		}
+	}
+	return nil
+}
+
+// Delegate enables controllers for the children of the group at path: in
+// its cgroup.subtree_control and in those of all its ancestors below the
+// mountpoint. None of these groups may hold processes itself.
+func Delegate(path string, controllers []string) error {
+	if len(controllers) == 0 {
+		return nil
+	}
+	dirs, err := lineage(path)
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		available, err := os.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+		if err != nil {
+			return err
+		}
+		var enable []string
+		for _, c := range controllers {
+			if !contains(strings.Fields(string(available)), c) {
+				return fmt.Errorf("controller %s is not available in %s (owned by cgroup v1?)", c, dir)
+			}
+			enable = append(enable, "+"+c)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte(strings.Join(enable, " ")), 0644); err != nil {
+			return fmt.Errorf("failed to enable %s in %s: %w", strings.Join(controllers, ", "), dir, err)
+		}
+	}
+	return nil
+}
+
+// DelegateAvailable enables controllers like Delegate, skipping those the
+// hierarchy does not have (bound to cgroup v1) or that a group refuses.
+func DelegateAvailable(path string, controllers []string) {
+	dirs, err := lineage(path)
+	if err != nil {
+		return
+	}
+	for _, dir := range dirs {
+		available, err := os.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+		if err != nil {
+			return
+		}
+		var enabled []string
+		for _, c := range controllers {
+			if contains(strings.Fields(string(available)), c) &&
+				os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+"+c), 0644) == nil {
+				enabled = append(enabled, c)
+			}
+		}
+		controllers = enabled
+	}
+}
+
+// lineage returns the groups from the mountpoint down to path.
+func lineage(path string) ([]string, error) {
+	root, err := Mountpoint()
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil, fmt.Errorf("%s is not in the cgroup v2 hierarchy", path)
+	}
+
+	dirs := []string{root}
+	if rel != "." {
+		for _, part := range strings.Split(rel, string(filepath.Separator)) {
+			dirs = append(dirs, filepath.Join(dirs[len(dirs)-1], part))
+		}
+	}
+	return dirs, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
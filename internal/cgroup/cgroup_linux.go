@@ -0,0 +1,41 @@
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// Mountpoint returns where the cgroup v2 hierarchy is mounted: /sys/fs/cgroup
+// on unified systems, usually /sys/fs/cgroup/unified on hybrid ones.
+func Mountpoint() (string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// id parent major:minor root mountpoint options ... - fstype source
+		fields := strings.Fields(scanner.Text())
+		for i, field := range fields {
+			if field == "-" && i+1 < len(fields) && fields[i+1] == "cgroup2" && len(fields) > 4 {
+				return fields[4], nil
+			}
+		}
+	}
+	return "", errors.New("cgroup v2 is not mounted")
+}
+
+// inode returns the inode number of a cgroup directory, which is its cgroup
+// ID on 64-bit kernels.
+func inode(path string) (uint64, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return 0, err
+	}
+	return st.Ino, nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package cgroup
+
+import "errors"
+
+var errUnsupported = errors.New("cgroups require Linux")
+
+func Mountpoint() (string, error) {
+	return "", errUnsupported
+}
+
+func inode(path string) (uint64, error) {
+	return 0, errUnsupported
+}
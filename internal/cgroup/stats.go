@@ -0,0 +1,132 @@
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Stats is the resource usage of a group over one run, from its cpu.stat,
+// memory.peak and io.stat files. Fields stay zero where the controller is
+// not enabled for the group; it accounts for every process of the run,
+// including the ones its parent did not wait for.
+type Stats struct {
+	CPUUsageUsec    uint64 `json:"cpu_usage_usec"`
+	CPUUserUsec     uint64 `json:"cpu_user_usec"`
+	CPUSystemUsec   uint64 `json:"cpu_system_usec"`
+	NrThrottled     uint64 `json:"nr_throttled,omitempty"`
+	ThrottledUsec   uint64 `json:"throttled_usec,omitempty"`
+	MemoryPeakBytes uint64 `json:"memory_peak_bytes,omitempty"`
+	IOReadBytes     uint64 `json:"io_read_bytes,omitempty"`
+	IOWriteBytes    uint64 `json:"io_write_bytes,omitempty"`
+	IOReadOps       uint64 `json:"io_read_ops,omitempty"`
+	IOWriteOps      uint64 `json:"io_write_ops,omitempty"`
+}
+
+// Usage measures the usage of a group over one run.
+type Usage struct {
+	dir    string
+	before Stats
+	peak   *os.File
+}
+
+// StartUsage snapshots the counters of the group at dir. The memory peak of
+// a fresh group is already the run's own. That of a group reused across runs
+// is reset for this reader, so that it is not the highest so far; kernels
+// before 6.12 cannot reset it, and the peak is then not recorded.
+func StartUsage(dir string, fresh bool) *Usage {
+	u := &Usage{dir: dir, before: readCounters(dir)}
+	path := filepath.Join(dir, "memory.peak")
+	if f, err := os.OpenFile(path, os.O_RDWR, 0); err == nil {
+		if _, err := f.WriteString("reset\n"); err == nil || fresh {
+			u.peak = f
+		} else {
+			f.Close()
+		}
+	} else if f, err := os.Open(path); err == nil && fresh {
+		u.peak = f
+	}
+	return u
+}
+
+// Stop returns the usage since StartUsage.
+func (u *Usage) Stop() Stats {
+	after := readCounters(u.dir)
+	b := u.before
+	s := Stats{
+		CPUUsageUsec:  after.CPUUsageUsec - b.CPUUsageUsec,
+		CPUUserUsec:   after.CPUUserUsec - b.CPUUserUsec,
+		CPUSystemUsec: after.CPUSystemUsec - b.CPUSystemUsec,
+		NrThrottled:   after.NrThrottled - b.NrThrottled,
+		ThrottledUsec: after.ThrottledUsec - b.ThrottledUsec,
+		IOReadBytes:   after.IOReadBytes - b.IOReadBytes,
+		IOWriteBytes:  after.IOWriteBytes - b.IOWriteBytes,
+		IOReadOps:     after.IOReadOps - b.IOReadOps,
+		IOWriteOps:    after.IOWriteOps - b.IOWriteOps,
+	}
+	if u.peak != nil {
+		buf := make([]byte, 32)
+		if n, _ := u.peak.ReadAt(buf, 0); n > 0 {
+			s.MemoryPeakBytes, _ = strconv.ParseUint(strings.TrimSpace(string(buf[:n])), 10, 64)
+		}
+		u.peak.Close()
+	}
+	return s
+}
+
+// readCounters reads the cumulative counters of cpu.stat and io.stat; io.stat
+// is summed over devices.
+func readCounters(dir string) Stats {
+	var s Stats
+	if data, err := os.ReadFile(filepath.Join(dir, "cpu.stat")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				continue
+			}
+			v, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			switch fields[0] {
+			case "usage_usec":
+				s.CPUUsageUsec = v
+			case "user_usec":
+				s.CPUUserUsec = v
+			case "system_usec":
+				s.CPUSystemUsec = v
+			case "nr_throttled":
+				s.NrThrottled = v
+			case "throttled_usec":
+				s.ThrottledUsec = v
+			}
+		}
+	}
+	// MAJ:MIN rbytes=N wbytes=N rios=N wios=N dbytes=N dios=N
+	if data, err := os.ReadFile(filepath.Join(dir, "io.stat")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			for _, field := range strings.Fields(line) {
+				key, value, ok := strings.Cut(field, "=")
+				if !ok {
+					continue
+				}
+				v, err := strconv.ParseUint(value, 10, 64)
+				if err != nil {
+					continue
+				}
+				switch key {
+				case "rbytes":
+					s.IOReadBytes += v
+				case "wbytes":
+					s.IOWriteBytes += v
+				case "rios":
+					s.IOReadOps += v
+				case "wios":
+					s.IOWriteOps += v
+				}
+			}
+		}
+	}
+	return s
+}
@@ -0,0 +1,173 @@
+// Package collect defines the run-time collectors that instrument each
+// measured run (eBPF, /proc sampling, ...) and the registry used to enable
+// them by name with --collect.
+package collect
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/processgain/internal/ebpf"
+)
+
+// Target is what a collector observes during one run.
+type Target struct {
+	// CgroupDir and CgroupID identify the scenario's cgroup v2 group, when
+	// one was created; the whole process tree of the run belongs to it.
+	CgroupDir string
+	CgroupID  uint64
+}
+
+// Collector instruments runs: it is started before each measured run and
+// stopped once the run has exited.
+type Collector interface {
+	// Name is the identifier used with --collect.
+	Name() string
+	// Probe returns why the collector cannot run on this machine, or nil.
+	Probe() error
+	// Info describes the collector's setup for the console banner.
+	Info() string
+	Start(t Target) error
+	Stop() (Sample, error)
+	// Close releases the collector's resources after the last run.
+	Close()
+}
+
+// ProcessWatcher is implemented by collectors that follow the run's process
+// tree from its root PID, which is only known once the run's process exists.
+// Watch is called before that process runs the script.
+type ProcessWatcher interface {
+	Watch(pid int)
+}
+
+// CgroupScoper is implemented by collectors that can limit themselves to a
+// cgroup: per-scenario cgroups are created when one of them wants it.
+type CgroupScoper interface {
+	WantsCgroup() bool
+}
+
+// Sample is what a collector recorded during one run. Insight collectors
+// (eBPF, /proc) fill the common "where did the time go" model; counters are
+// stored on the run and get the same statistics as the wall time; the others
+// report named values, compared in their own report section.
+type Sample struct {
+	Insights *ebpf.Metrics
+	Counters []Value
+	Values   []Value
+}
+
+// Value is one named measurement of a run.
+type Value struct {
+	Name  string  `json:"name"`
+	Unit  string  `json:"unit,omitempty"`
+	Value float64 `json:"value"`
+	// Better is "lower", "higher" or empty when the direction is unknown
+	Better string `json:"better,omitempty"`
+}
+
+// Factory creates a collector.
+type Factory func() Collector
+
+type registration struct {
+	description string
+	factory     Factory
+	// custom collectors can be redefined, by each suite of a spec
+	custom bool
+}
+
+var registry = map[string]registration{}
+
+// Register makes a collector available to --collect under name.
+func Register(name, description string, f Factory) {
+	registry[name] = registration{description: description, factory: f}
+}
+
+// Names lists the registered collectors, sorted.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Description returns the one-line description of a registered collector.
+func Description(name string) string {
+	return registry[name].description
+}
+
+// New creates the collector registered under name.
+func New(name string) (Collector, error) {
+	r, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown collector %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	return r.factory(), nil
+}
+
+// Set is the collectors enabled for a suite, started and stopped together.
+type Set []Collector
+
+// Start starts every collector, returning the first error.
+func (s Set) Start(t Target) error {
+	for _, c := range s {
+		if err := c.Start(t); err != nil {
+			return fmt.Errorf("%s: %w", c.Name(), err)
+		}
+	}
+	return nil
+}
+
+// Watch hands the run's PID to the collectors following the process tree.
+func (s Set) Watch(pid int) {
+	for _, c := range s {
+		if w, ok := c.(ProcessWatcher); ok {
+			w.Watch(pid)
+		}
+	}
+}
+
+// WantsCgroup reports whether any collector would use per-scenario cgroups.
+func (s Set) WantsCgroup() bool {
+	for _, c := range s {
+		if sc, ok := c.(CgroupScoper); ok && sc.WantsCgroup() {
+			return true
+		}
+	}
+	return false
+}
+
+// Stop stops every collector. Insights of several collectors are merged
+// into one record and counters are concatenated; values are returned per
+// collector name, with an empty entry for a collector that failed so that
+// runs stay aligned.
+func (s Set) Stop() (Sample, map[string][]Value) {
+	var merged Sample
+	values := make(map[string][]Value)
+	for _, c := range s {
+		sample, err := c.Stop()
+		if err == nil && sample.Insights != nil {
+			if merged.Insights == nil {
+				merged.Insights = sample.Insights
+			} else {
+				merged.Insights.Merge(*sample.Insights)
+			}
+			continue
+		}
+		if err == nil && sample.Counters != nil {
+			merged.Counters = append(merged.Counters, sample.Counters...)
+			continue
+		}
+		values[c.Name()] = sample.Values
+	}
+	return merged, values
+}
+
+// Close releases every collector.
+func (s Set) Close() {
+	for _, c := range s {
+		c.Close()
+	}
+}
@@ -0,0 +1,130 @@
+package collect
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// stopGrace is how long a command collector may take to print its values
+// after SIGINT before it is killed, along with anything it started.
+const stopGrace = 5 * time.Second
+
+// RegisterCommand registers a user-defined collector: a shell command
+// started with each run and interrupted (SIGINT) when the run exits. It
+// gets the run's PID and cgroup in CORECUT_PID and CORECUT_CGROUP, and
+// prints one value per line on stdout:
+//
+//	name=value [unit] [lower|higher]
+func RegisterCommand(name, command string) error {
+	if r, ok := registry[name]; ok && !r.custom {
+		return fmt.Errorf("collector %q is built in", name)
+	}
+	registry[name] = registration{
+		description: "user-defined: " + command,
+		factory: func() Collector {
+			return &commandCollector{name: name, command: command}
+		},
+		custom: true,
+	}
+	return nil
+}
+
+type commandCollector struct {
+	name, command string
+
+	target Target
+	cmd    *exec.Cmd
+	stdout bytes.Buffer
+}
+
+func (c *commandCollector) Name() string { return c.name }
+
+func (c *commandCollector) Probe() error {
+	_, err := exec.LookPath("/bin/bash")
+	return err
+}
+
+func (c *commandCollector) Info() string { return c.command }
+
+func (c *commandCollector) Start(t Target) error {
+	c.target = t
+	c.cmd = nil
+	return nil
+}
+
+// Watch starts the command once the run's PID is known.
+func (c *commandCollector) Watch(pid int) {
+	c.stdout.Reset()
+	cmd := exec.Command("/bin/bash", "-c", c.command)
+	cmd.Env = append(os.Environ(),
+		"CORECUT_PID="+strconv.Itoa(pid),
+		"CORECUT_CGROUP="+c.target.CgroupDir,
+	)
+	cmd.Stdout = &c.stdout
+	cmd.Stderr = os.Stderr
+	// Its own process group, so that the signals reach the whole pipeline
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return
+	}
+	c.cmd = cmd
+}
+
+func (c *commandCollector) Stop() (Sample, error) {
+	if c.cmd == nil {
+		return Sample{}, fmt.Errorf("%s did not start", c.name)
+	}
+	cmd := c.cmd
+	c.cmd = nil
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGINT)
+	select {
+	case <-done:
+	case <-time.After(stopGrace):
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+	}
+	return Sample{Values: parseValues(c.stdout.String())}, nil
+}
+
+func (c *commandCollector) Close() {}
+
+// parseValues reads "name=value [unit] [lower|higher]" lines, ignoring
+// anything else.
+func parseValues(output string) []Value {
+	var values []Value
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		name, raw, ok := strings.Cut(fields[0], "=")
+		if !ok || name == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			continue
+		}
+		v := Value{Name: name, Value: n}
+		for _, f := range fields[1:] {
+			if f == "lower" || f == "higher" {
+				v.Better = f
+			} else if v.Unit == "" {
+				v.Unit = f
+			}
+		}
+		values = append(values, v)
+	}
+	return values
+}
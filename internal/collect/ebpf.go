@@ -0,0 +1,58 @@
+package collect
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/processgain/internal/ebpf"
+)
+
+func init() {
+	Register("ebpf", "eBPF: runqueue and I/O latency, off-CPU stacks, syscalls (root)", func() Collector {
+		return &ebpfCollector{}
+	})
+}
+
+// ebpfCollector adapts ebpf.Collector, scoped to the scenario's cgroup when
+// the native backend is loaded.
+type ebpfCollector struct {
+	c *ebpf.Collector
+}
+
+func (e *ebpfCollector) Name() string { return "ebpf" }
+
+func (e *ebpfCollector) Probe() error {
+	if e.c == nil {
+		e.c = ebpf.NewCollector()
+	}
+	if e.c.IsAvailable() {
+		return nil
+	}
+	if err := e.c.NativeError(); err != nil {
+		return fmt.Errorf("needs root and kernel BTF, or bcc/bpftrace (native: %v)", err)
+	}
+	return errors.New("needs root and kernel BTF, or bcc/bpftrace")
+}
+
+func (e *ebpfCollector) Info() string {
+	return e.c.Backend() + " backend"
+}
+
+func (e *ebpfCollector) WantsCgroup() bool {
+	return e.c.Backend() == ebpf.BackendNative
+}
+
+func (e *ebpfCollector) Start(t Target) error {
+	e.c.StartCgroup(t.CgroupID)
+	return nil
+}
+
+func (e *ebpfCollector) Stop() (Sample, error) {
+	return Sample{Insights: e.c.Stop()}, nil
+}
+
+func (e *ebpfCollector) Close() {
+	if e.c != nil {
+		e.c.Close()
+	}
+}
@@ -0,0 +1,84 @@
+package collect
+
+import (
+	"github.com/processgain/internal/perf"
+)
+
+func init() {
+	Register("perf", "perf_event_open: instructions, cycles, IPC, cache/branch misses, task clock, switches, migrations", func() Collector {
+		return &perfCollector{}
+	})
+}
+
+// perfCollector counts the events of the run's process tree, falling back
+// to the software counters where the hardware ones are unavailable.
+type perfCollector struct {
+	c   *perf.Counter
+	err error
+}
+
+func (p *perfCollector) Name() string { return "perf" }
+
+func (p *perfCollector) Probe() error {
+	if p.c == nil {
+		p.c, p.err = perf.New()
+	}
+	return p.err
+}
+
+func (p *perfCollector) Info() string {
+	info := "hardware and software counters"
+	if !p.c.Hardware() {
+		info = "software counters only, no hardware PMU"
+	}
+	if p.c.UserOnly() {
+		info += ", user space only"
+	}
+	return info
+}
+
+func (p *perfCollector) Start(t Target) error {
+	p.err = nil
+	return nil
+}
+
+// Watch attaches the counters while the run's process is held before its
+// script, so every child the script forks is counted.
+func (p *perfCollector) Watch(pid int) {
+	p.err = p.c.Attach(pid)
+}
+
+func (p *perfCollector) Stop() (Sample, error) {
+	if p.err != nil {
+		return Sample{}, p.err
+	}
+	counts, err := p.c.Read()
+	if err != nil {
+		return Sample{}, err
+	}
+	var counters []Value
+	for _, name := range []string{
+		perf.Instructions, perf.Cycles, perf.IPC, perf.CacheMisses, perf.BranchMisses,
+		perf.TaskClock, perf.ContextSwitches, perf.CPUMigrations,
+	} {
+		v, ok := counts[name]
+		if !ok {
+			continue
+		}
+		counter := Value{Name: name, Unit: "count", Value: v, Better: "lower"}
+		switch name {
+		case perf.IPC:
+			counter.Unit, counter.Better = "", "higher"
+		case perf.TaskClock:
+			counter.Unit, counter.Value = "ms", v/1e6
+		}
+		counters = append(counters, counter)
+	}
+	return Sample{Counters: counters}, nil
+}
+
+func (p *perfCollector) Close() {
+	if p.c != nil {
+		p.c.Close()
+	}
+}
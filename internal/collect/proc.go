@@ -0,0 +1,43 @@
+package collect
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/processgain/internal/procfs"
+)
+
+func init() {
+	Register("proc", "/proc sampling: runqueue wait, I/O bytes, context switches, tasks (unprivileged)", func() Collector {
+		return &procCollector{s: procfs.New(procfs.DefaultInterval)}
+	})
+}
+
+// procCollector samples the run's process tree from /proc.
+type procCollector struct {
+	s *procfs.Sampler
+}
+
+func (p *procCollector) Name() string { return "proc" }
+
+func (p *procCollector) Probe() error {
+	if !procfs.Available() {
+		return errors.New("/proc/<pid>/schedstat not available")
+	}
+	return nil
+}
+
+func (p *procCollector) Info() string {
+	return fmt.Sprintf("sampling every %v", p.s.Interval)
+}
+
+func (p *procCollector) Start(t Target) error { return nil }
+
+func (p *procCollector) Watch(pid int) { p.s.Start(pid) }
+
+func (p *procCollector) Stop() (Sample, error) {
+	m := p.s.Stop()
+	return Sample{Insights: &m}, nil
+}
+
+func (p *procCollector) Close() {}
@@ -0,0 +1,75 @@
+package collect
+
+import (
+	"errors"
+
+	"github.com/processgain/internal/cgroup"
+	"github.com/processgain/internal/psi"
+)
+
+func init() {
+	Register("psi", "Pressure stall time: CPU, memory and I/O (per scenario cgroup, else system-wide)", func() Collector {
+		return &psiCollector{}
+	})
+}
+
+// psiCollector snapshots the pressure stall totals before and after each
+// run, from the scenario's cgroup when it has one.
+type psiCollector struct {
+	dir    string
+	before psi.Snapshot
+}
+
+func (p *psiCollector) Name() string { return "psi" }
+
+func (p *psiCollector) Probe() error {
+	if !psi.Available() {
+		return errors.New("/proc/pressure not available (kernel without PSI, or psi=0)")
+	}
+	return nil
+}
+
+func (p *psiCollector) Info() string {
+	if p.WantsCgroup() {
+		return "per scenario cgroup"
+	}
+	return "system-wide"
+}
+
+func (p *psiCollector) WantsCgroup() bool { return cgroup.Available() }
+
+func (p *psiCollector) Start(t Target) error {
+	p.dir = t.CgroupDir
+	before, err := psi.Read(p.dir)
+	if err != nil && p.dir != "" {
+		// Groups without the pressure files: fall back to the whole system
+		p.dir = ""
+		before, err = psi.Read(p.dir)
+	}
+	p.before = before
+	return err
+}
+
+func (p *psiCollector) Stop() (Sample, error) {
+	if p.before == nil {
+		return Sample{}, errors.New("psi not started")
+	}
+	after, err := psi.Read(p.dir)
+	if err != nil {
+		return Sample{}, err
+	}
+	delta := after.Sub(p.before)
+	p.before = nil
+
+	var values []Value
+	for _, res := range psi.Resources {
+		stall := delta[res]
+		values = append(values, Value{Name: res + " some", Unit: "ms", Value: float64(stall.Some) / 1000, Better: "lower"})
+		if stall.HasFull {
+			values = append(values, Value{Name: res + " full", Unit: "ms", Value: float64(stall.Full) / 1000, Better: "lower"})
+		}
+	}
+	return Sample{Values: values}, nil
+}
+
+func (p *psiCollector) Close() {}
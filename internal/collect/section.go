@@ -0,0 +1,115 @@
+package collect
+
+import "sort"
+
+// Section is the report section of one collector: the mean of each of its
+// values per run, baseline against a candidate.
+type Section struct {
+	Collector string `json:"collector"`
+	Title     string `json:"title"`
+	Rows      []Row  `json:"rows"`
+}
+
+// Row compares one value of a collector.
+type Row struct {
+	Name         string  `json:"name"`
+	Unit         string  `json:"unit,omitempty"`
+	Baseline     float64 `json:"baseline"`
+	Optimized    float64 `json:"optimized"`
+	DeltaPercent float64 `json:"delta_percent"`
+	// Outcome is "better" or "worse" when the value has a direction
+	Outcome string `json:"outcome,omitempty"`
+}
+
+// Compare builds one section per collector, in the given order, from the
+// values recorded on each run (collector name → runs → values). Collectors
+// that recorded nothing on either side are left out.
+func Compare(order []string, baseline, optimized map[string][][]Value) []Section {
+	var sections []Section
+	for _, name := range order {
+		base := meanValues(baseline[name])
+		opt := meanValues(optimized[name])
+		if len(base) == 0 && len(opt) == 0 {
+			continue
+		}
+
+		section := Section{Collector: name, Title: Description(name)}
+		if registry[name].custom {
+			section.Title = name
+		}
+		for _, key := range unionKeys(base, opt) {
+			b, o := base[key], opt[key]
+			v := b.value
+			if v.Name == "" {
+				v = o.value
+			}
+			row := Row{Name: v.Name, Unit: v.Unit, Baseline: b.mean, Optimized: o.mean}
+			if row.Baseline != 0 {
+				row.DeltaPercent = (row.Optimized - row.Baseline) / row.Baseline * 100
+			}
+			switch {
+			case row.Optimized == row.Baseline || v.Better == "":
+			case (row.Optimized < row.Baseline) == (v.Better == "lower"):
+				row.Outcome = "better"
+			default:
+				row.Outcome = "worse"
+			}
+			section.Rows = append(section.Rows, row)
+		}
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+type meanValue struct {
+	value Value
+	mean  float64
+	order int
+}
+
+// meanValues averages each named value over the runs, keeping the order in
+// which values first appear.
+func meanValues(runs [][]Value) map[string]meanValue {
+	means := make(map[string]meanValue)
+	if len(runs) == 0 {
+		return means
+	}
+	for _, run := range runs {
+		for _, v := range run {
+			m, ok := means[v.Name]
+			if !ok {
+				m = meanValue{value: v, order: len(means)}
+			}
+			m.mean += v.Value / float64(len(runs))
+			means[v.Name] = m
+		}
+	}
+	return means
+}
+
+func unionKeys(a, b map[string]meanValue) []string {
+	var keys []string
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		oi, oj := orderOf(keys[i], a, b), orderOf(keys[j], a, b)
+		if oi != oj {
+			return oi < oj
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func orderOf(key string, a, b map[string]meanValue) int {
+	if m, ok := a[key]; ok {
+		return m.order
+	}
+	return len(a) + b[key].order
+}
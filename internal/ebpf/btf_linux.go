@@ -0,0 +1,115 @@
+package ebpf
+
+import (
+	"fmt"
+
+	"github.com/cilium/ebpf/btf"
+)
+
+// kernelLayout holds the kernel structure offsets the native programs need.
+// They are read from the running kernel's BTF, so the programs built into
+// the binary are relocated for whatever kernel they are loaded on.
+type kernelLayout struct {
+	taskPid   int32
+	taskState int32
+	// Pointer chain from a task to its cgroup v2 ID:
+	// task->cgroups->dfl_cgrp->kn->id
+	taskCgroups   int32
+	cssSetDflCgrp int32
+	cgroupKn      int32
+	kernfsID      int32
+	// Number of arguments of the block_rq_issue and block_rq_complete
+	// tracepoints, which lost their request_queue argument in 5.11.
+	rqIssueArgs    int
+	rqCompleteArgs int
+}
+
+func loadKernelLayout() (*kernelLayout, error) {
+	spec, err := btf.LoadKernelSpec()
+	if err != nil {
+		return nil, fmt.Errorf("kernel BTF not available: %w", err)
+	}
+
+	var task *btf.Struct
+	if err := spec.TypeByName("task_struct", &task); err != nil {
+		return nil, fmt.Errorf("task_struct: %w", err)
+	}
+
+	l := &kernelLayout{}
+	if l.taskPid, err = memberOffset(task, "pid"); err != nil {
+		return nil, err
+	}
+	// task_struct.state was renamed __state in 5.14
+	if l.taskState, err = memberOffset(task, "__state"); err != nil {
+		if l.taskState, err = memberOffset(task, "state"); err != nil {
+			return nil, err
+		}
+	}
+
+	chain := []struct {
+		typ, member string
+		dst         *int32
+	}{
+		{"task_struct", "cgroups", &l.taskCgroups},
+		{"css_set", "dfl_cgrp", &l.cssSetDflCgrp},
+		{"cgroup", "kn", &l.cgroupKn},
+		{"kernfs_node", "id", &l.kernfsID},
+	}
+	for _, c := range chain {
+		var s *btf.Struct
+		if err := spec.TypeByName(c.typ, &s); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.typ, err)
+		}
+		if *c.dst, err = memberOffset(s, c.member); err != nil {
+			return nil, err
+		}
+	}
+
+	l.rqIssueArgs = tracepointArgs(spec, "block_rq_issue")
+	l.rqCompleteArgs = tracepointArgs(spec, "block_rq_complete")
+
+	return l, nil
+}
+
+// memberOffset returns the byte offset of a member of a struct or union,
+// looking into anonymous structs and unions.
+func memberOffset(t btf.Type, name string) (int32, error) {
+	var members []btf.Member
+	switch t := t.(type) {
+	case *btf.Struct:
+		members = t.Members
+	case *btf.Union:
+		members = t.Members
+	}
+
+	for _, m := range members {
+		if m.Name == name {
+			return int32(m.Offset.Bytes()), nil
+		}
+		if m.Name == "" {
+			if off, err := memberOffset(btf.UnderlyingType(m.Type), name); err == nil {
+				return int32(m.Offset.Bytes()) + off, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("member %s not found in kernel BTF", name)
+}
+
+// tracepointArgs returns the number of arguments of a raw tracepoint, or 0
+// if the kernel does not describe it.
+func tracepointArgs(spec *btf.Spec, name string) int {
+	var typedef *btf.Typedef
+	if err := spec.TypeByName("btf_trace_"+name, &typedef); err != nil {
+		return 0
+	}
+	ptr, ok := typedef.Type.(*btf.Pointer)
+	if !ok {
+		return 0
+	}
+	proto, ok := ptr.Target.(*btf.FuncProto)
+	if !ok {
+		return 0
+	}
+	// The first parameter is the tracepoint's private data pointer
+	return len(proto.Params) - 1
+}
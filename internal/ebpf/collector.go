@@ -10,7 +10,25 @@ import (
 	"sync"
 )
 
+// Scopes of the collected metrics.
+const (
+	ScopeCgroup      = "cgroup"
+	ScopeSystem      = "system"
+	ScopeProcessTree = "process-tree"
+)
+
+// Sources of the metrics: the eBPF collector, or the unprivileged /proc
+// sampler, which fills the same model with what /proc exposes.
+const (
+	SourceEbpf = "ebpf"
+	SourceProc = "proc"
+)
+
 type Metrics struct {
+	Source string `json:"source,omitempty"`
+	// Scope tells whether the metrics cover the scenario's cgroup or
+	// process tree only, or the whole system.
+	Scope             string             `json:"scope,omitempty"`
 	RunqueueLatencyUs float64            `json:"runqueue_latency_us,omitempty"`
 	RunqlatHistogram  map[string]int64   `json:"runqlat_histogram,omitempty"`
 	OffCpuTimeMs      float64            `json:"offcpu_time_ms,omitempty"`
@@ -18,7 +36,67 @@ type Metrics struct {
 	IoLatencyUs       float64            `json:"io_latency_us,omitempty"`
 	BiolatHistogram   map[string]int64   `json:"biolat_histogram,omitempty"`
 	TopSyscalls       map[string]int64   `json:"top_syscalls,omitempty"`
-	SyscallLatencyUs  map[string]float64 `json:"syscall_latency_us,omitempty"`
+	SyscallLatencyUs  map[string]float64 `json:"syscall_latency_us,omitempty"` // total per syscall
+
+	// Totals over the process tree, from the /proc sampler
+	RunqueueWaitMs      float64 `json:"runqueue_wait_ms,omitempty"`
+	ReadBytes           int64   `json:"read_bytes,omitempty"`
+	WriteBytes          int64   `json:"write_bytes,omitempty"`
+	ReadChars           int64   `json:"read_chars,omitempty"`
+	WriteChars          int64   `json:"write_chars,omitempty"`
+	VoluntarySwitches   int64   `json:"voluntary_switches,omitempty"`
+	InvoluntarySwitches int64   `json:"involuntary_switches,omitempty"`
+	Processes           int     `json:"processes,omitempty"`
+	MaxThreads          int     `json:"max_threads,omitempty"`
+}
+
+// Merge fills the metrics m lacks from o, recorded by another collector
+// over the same run. m's values and scope win where both have data.
+func (m *Metrics) Merge(o Metrics) {
+	if o.Source != "" && m.Source != o.Source {
+		m.Source += "+" + o.Source
+	}
+	if m.Scope == "" {
+		m.Scope = o.Scope
+	}
+	mergeFloat := func(dst *float64, v float64) {
+		if *dst == 0 {
+			*dst = v
+		}
+	}
+	mergeInt := func(dst *int64, v int64) {
+		if *dst == 0 {
+			*dst = v
+		}
+	}
+	mergeFloat(&m.RunqueueLatencyUs, o.RunqueueLatencyUs)
+	mergeFloat(&m.OffCpuTimeMs, o.OffCpuTimeMs)
+	mergeFloat(&m.IoLatencyUs, o.IoLatencyUs)
+	mergeFloat(&m.RunqueueWaitMs, o.RunqueueWaitMs)
+	mergeInt(&m.ReadBytes, o.ReadBytes)
+	mergeInt(&m.WriteBytes, o.WriteBytes)
+	mergeInt(&m.ReadChars, o.ReadChars)
+	mergeInt(&m.WriteChars, o.WriteChars)
+	mergeInt(&m.VoluntarySwitches, o.VoluntarySwitches)
+	mergeInt(&m.InvoluntarySwitches, o.InvoluntarySwitches)
+	if len(m.RunqlatHistogram) == 0 {
+		m.RunqlatHistogram = o.RunqlatHistogram
+	}
+	if len(m.BiolatHistogram) == 0 {
+		m.BiolatHistogram = o.BiolatHistogram
+	}
+	if len(m.OffCpuTopStacks) == 0 {
+		m.OffCpuTopStacks = o.OffCpuTopStacks
+	}
+	if len(m.TopSyscalls) == 0 {
+		m.TopSyscalls, m.SyscallLatencyUs = o.TopSyscalls, o.SyscallLatencyUs
+	}
+	if m.Processes == 0 {
+		m.Processes = o.Processes
+	}
+	if m.MaxThreads == 0 {
+		m.MaxThreads = o.MaxThreads
+	}
 }
 
 type StackTrace struct {
@@ -28,22 +106,34 @@ type StackTrace struct {
 }
 
 type AggregatedMetrics struct {
+	Source            string           `json:"source,omitempty"`
+	Scope             string           `json:"scope,omitempty"`
 	RunqueueLatencyUs float64          `json:"runqueue_latency_us"`
 	OffCpuTimeMs      float64          `json:"offcpu_time_ms"`
 	IoLatencyUs       float64          `json:"io_latency_us"`
 	TopSyscalls       map[string]int64 `json:"top_syscalls"`
 }
 
+// Collection backends, in order of preference.
+const (
+	BackendNative   = "native"
+	BackendBcc      = "bcc"
+	BackendBpftrace = "bpftrace"
+)
+
 type Collector struct {
 	available   bool
 	hasBpftrace bool
 	hasBcc      bool
+	native      *nativeTracer
+	nativeErr   error
 	mu          sync.Mutex
 	running     bool
 	stopChan    chan struct{}
 	pid         int
 	results     *Metrics
 	cmds        []*exec.Cmd
+	wg          sync.WaitGroup
 }
 
 func NewCollector() *Collector {
@@ -61,6 +151,14 @@ func (c *Collector) checkAvailability() {
 		return
 	}
 
+	// Prefer the in-process collector; bcc and bpftrace remain as fallbacks
+	if t, err := newNativeTracer(); err == nil {
+		c.native = t
+		c.available = true
+	} else {
+		c.nativeErr = err
+	}
+
 	// Check for bpftrace
 	if _, err := exec.LookPath("bpftrace"); err == nil {
 		c.hasBpftrace = true
@@ -87,7 +185,40 @@ func (c *Collector) IsAvailable() bool {
 	return c.available
 }
 
+// Backend returns the collection backend in use, or "" when unavailable.
+func (c *Collector) Backend() string {
+	switch {
+	case c.native != nil:
+		return BackendNative
+	case c.hasBcc:
+		return BackendBcc
+	case c.hasBpftrace:
+		return BackendBpftrace
+	}
+	return ""
+}
+
+// NativeError explains why the native backend could not be loaded.
+func (c *Collector) NativeError() error {
+	return c.nativeErr
+}
+
+// Close releases the native programs and maps.
+func (c *Collector) Close() {
+	if c.native != nil {
+		c.native.close()
+		c.native = nil
+	}
+}
+
 func (c *Collector) Start() {
+	c.StartCgroup(0)
+}
+
+// StartCgroup starts collecting for the processes of one cgroup v2 group.
+// Only the native backend can filter by cgroup; the bcc and bpftrace tools,
+// and a zero cgroupID, trace the whole system.
+func (c *Collector) StartCgroup(cgroupID uint64) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -102,36 +233,60 @@ func (c *Collector) Start() {
 		BiolatHistogram:  make(map[string]int64),
 		TopSyscalls:      make(map[string]int64),
 		SyscallLatencyUs: make(map[string]float64),
+		Source:           SourceEbpf,
+		Scope:            ScopeSystem,
 	}
 	c.cmds = nil
 
+	if c.native != nil {
+		if err := c.native.start(cgroupID); err == nil {
+			if cgroupID != 0 {
+				c.results.Scope = ScopeCgroup
+			}
+			return
+		}
+	}
+
 	// Start collection tools in background
+	c.wg.Add(4)
 	go c.collectRunqlat()
 	go c.collectBiolatency()
+	go c.collectOffcputime()
+	go c.collectSyscount()
 }
 
 func (c *Collector) Stop() *Metrics {
 	c.mu.Lock()
-	defer c.mu.Unlock()
 
 	if !c.running {
+		c.mu.Unlock()
 		return &Metrics{}
 	}
 
 	close(c.stopChan)
 	c.running = false
 
+	if c.native != nil && c.native.attached() {
+		c.native.stop(c.results)
+		c.mu.Unlock()
+		return c.results
+	}
+
 	// Stop all running commands
 	for _, cmd := range c.cmds {
 		if cmd.Process != nil {
 			cmd.Process.Kill()
 		}
 	}
+	c.mu.Unlock()
 
+	// Wait for the tools' output to be parsed
+	c.wg.Wait()
 	return c.results
 }
 
 func (c *Collector) collectRunqlat() {
+	defer c.wg.Done()
 	var cmd *exec.Cmd
 	var stdout bytes.Buffer
 
@@ -176,6 +331,7 @@ interval:s:1 { exit(); }`
 }
 
 func (c *Collector) collectBiolatency() {
+	defer c.wg.Done()
 	var cmd *exec.Cmd
 	var stdout bytes.Buffer
 
@@ -211,6 +367,81 @@ func (c *Collector) collectBiolatency() {
 	c.mu.Unlock()
 }
 
+// runUntilStopped runs a bcc tool until the collector stops, then sends it
+// SIGINT: offcputime and syscount only print their summary when
+// interrupted. It returns false when the tool is not installed.
+func (c *Collector) runUntilStopped(tool string, args ...string) (string, bool) {
+	var cmd *exec.Cmd
+	var stdout bytes.Buffer
+
+	if path, err := exec.LookPath(tool); err == nil {
+		cmd = exec.Command(path, args...)
+	} else if _, err := os.Stat("/usr/share/bcc/tools/" + tool); err == nil {
+		cmd = exec.Command("/usr/share/bcc/tools/"+tool, args...)
+	}
+
+	if cmd == nil {
+		return "", false
+	}
+
+	cmd.Stdout = &stdout
+	if err := cmd.Start(); err != nil {
+		return "", false
+	}
+
+	<-c.stopChan
+	cmd.Process.Signal(os.Interrupt)
+	cmd.Wait()
+	return stdout.String(), true
+}
+
+// collectOffcputime runs bcc's offcputime in folded mode.
+func (c *Collector) collectOffcputime() {
+	defer c.wg.Done()
+	output, ok := c.runUntilStopped("offcputime", "-f", "-d")
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	c.results.OffCpuTimeMs, c.results.OffCpuTopStacks = topStacks(parseFolded(output), maxRunStacks)
+	c.mu.Unlock()
+}
+
+// collectSyscount runs bcc's syscount with latencies, in microseconds.
+func (c *Collector) collectSyscount() {
+	defer c.wg.Done()
+	output, ok := c.runUntilStopped("syscount", "-L", "-T", "100")
+	if !ok {
+		return
+	}
+
+	counts, latencies := parseSyscount(output)
+	c.mu.Lock()
+	c.results.TopSyscalls = counts
+	c.results.SyscallLatencyUs = latencies
+	c.mu.Unlock()
+}
+
+// parseSyscount reads the summary of syscount -L:
+// "SYSCALL   COUNT   TIME (us)" followed by one line per syscall.
+func parseSyscount(output string) (map[string]int64, map[string]float64) {
+	counts := make(map[string]int64)
+	latencies := make(map[string]float64)
+	re := regexp.MustCompile(`^\s*(\S+)\s+(\d+)\s+([\d.]+)\s*$`)
+	for _, line := range strings.Split(output, "\n") {
+		matches := re.FindStringSubmatch(line)
+		if len(matches) < 4 {
+			continue
+		}
+		count, _ := strconv.ParseInt(matches[2], 10, 64)
+		us, _ := strconv.ParseFloat(matches[3], 64)
+		counts[matches[1]] = count
+		latencies[matches[1]] = us
+	}
+	return counts, latencies
+}
+
 func parseHistogram(output string) map[string]int64 {
 	hist := make(map[string]int64)
 	
@@ -265,12 +496,18 @@ func Aggregate(metrics []Metrics) AggregatedMetrics {
 
 	agg := AggregatedMetrics{
 		TopSyscalls: make(map[string]int64),
+		Source:      metrics[0].Source,
+		Scope:       metrics[0].Scope,
 	}
 
 	var runqSum, offcpuSum, ioSum float64
 	var runqCount, offcpuCount, ioCount int
 
 	for _, m := range metrics {
+		// A single system-wide run makes the aggregate system-wide
+		if m.Scope != agg.Scope {
+			agg.Scope = ScopeSystem
+		}
 		if m.RunqueueLatencyUs > 0 {
 			runqSum += m.RunqueueLatencyUs
 			runqCount++
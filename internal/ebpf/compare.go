@@ -0,0 +1,161 @@
+package ebpf
+
+import (
+	"math"
+	"sort"
+)
+
+// maxCompareSyscalls is the number of syscalls shown in comparisons.
+const maxCompareSyscalls = 15
+
+// Comparison holds the eBPF (or /proc) metrics of two scenarios side by
+// side.
+type Comparison struct {
+	Source            string         `json:"source"`
+	Scope             string         `json:"scope"`
+	Counters          []CounterDelta `json:"counters,omitempty"`
+	OffCpuBaselineMs  float64        `json:"offcpu_baseline_ms"`
+	OffCpuOptimizedMs float64        `json:"offcpu_optimized_ms"`
+	OffCpuStacks      []StackDelta   `json:"offcpu_stacks,omitempty"`
+	Syscalls          []SyscallDelta `json:"syscalls,omitempty"`
+}
+
+// CounterDelta compares one summary metric, as a mean per run.
+type CounterDelta struct {
+	Name         string  `json:"name"`
+	Unit         string  `json:"unit"`
+	Baseline     float64 `json:"baseline"`
+	Optimized    float64 `json:"optimized"`
+	DeltaPercent float64 `json:"delta_percent"`
+}
+
+// counters are the summary metrics compared, in display order. Those that
+// are zero on both sides (not collected by the source) are left out.
+var counters = []struct {
+	name, unit string
+	value      func(Metrics) float64
+}{
+	{"Runqueue latency (avg)", "μs", func(m Metrics) float64 { return m.RunqueueLatencyUs }},
+	{"Runqueue wait (total)", "ms", func(m Metrics) float64 { return m.RunqueueWaitMs }},
+	{"Off-CPU time (total)", "ms", func(m Metrics) float64 { return m.OffCpuTimeMs }},
+	{"I/O latency (avg)", "μs", func(m Metrics) float64 { return m.IoLatencyUs }},
+	{"Storage read", "KiB", func(m Metrics) float64 { return float64(m.ReadBytes) / 1024 }},
+	{"Storage written", "KiB", func(m Metrics) float64 { return float64(m.WriteBytes) / 1024 }},
+	{"Bytes read (all)", "KiB", func(m Metrics) float64 { return float64(m.ReadChars) / 1024 }},
+	{"Bytes written (all)", "KiB", func(m Metrics) float64 { return float64(m.WriteChars) / 1024 }},
+	{"Voluntary switches", "count", func(m Metrics) float64 { return float64(m.VoluntarySwitches) }},
+	{"Involuntary switches", "count", func(m Metrics) float64 { return float64(m.InvoluntarySwitches) }},
+	{"Processes", "count", func(m Metrics) float64 { return float64(m.Processes) }},
+	{"Max threads", "count", func(m Metrics) float64 { return float64(m.MaxThreads) }},
+}
+
+// SyscallDelta compares the count and total latency of one syscall, as
+// means per run.
+type SyscallDelta struct {
+	Name               string  `json:"name"`
+	BaselineCount      float64 `json:"baseline_count"`
+	OptimizedCount     float64 `json:"optimized_count"`
+	CountDelta         float64 `json:"count_delta"`
+	BaselineLatencyUs  float64 `json:"baseline_latency_us"`
+	OptimizedLatencyUs float64 `json:"optimized_latency_us"`
+	LatencyDeltaUs     float64 `json:"latency_delta_us"`
+}
+
+// Compare puts the eBPF metrics of the baseline and optimized runs side by
+// side. It returns nil when neither side has eBPF metrics.
+func Compare(baseline, optimized []Metrics) *Comparison {
+	if len(baseline) == 0 && len(optimized) == 0 {
+		return nil
+	}
+	agg := Aggregate(append(append([]Metrics(nil), baseline...), optimized...))
+	c := &Comparison{Source: agg.Source, Scope: agg.Scope}
+	compareCounters(c, baseline, optimized)
+	compareStacks(c, baseline, optimized)
+	compareSyscalls(c, baseline, optimized)
+	return c
+}
+
+func compareCounters(c *Comparison, baseline, optimized []Metrics) {
+	mean := func(metrics []Metrics, value func(Metrics) float64) float64 {
+		if len(metrics) == 0 {
+			return 0
+		}
+		var sum float64
+		for _, m := range metrics {
+			sum += value(m)
+		}
+		return sum / float64(len(metrics))
+	}
+	for _, counter := range counters {
+		d := CounterDelta{
+			Name:      counter.name,
+			Unit:      counter.unit,
+			Baseline:  mean(baseline, counter.value),
+			Optimized: mean(optimized, counter.value),
+		}
+		if d.Baseline == 0 && d.Optimized == 0 {
+			continue
+		}
+		if d.Baseline != 0 {
+			d.DeltaPercent = (d.Optimized - d.Baseline) / d.Baseline * 100
+		}
+		c.Counters = append(c.Counters, d)
+	}
+}
+
+// compareSyscalls diffs the syscalls of both sides, sorted by impact: the
+// change in total latency first, then the change in count.
+func compareSyscalls(c *Comparison, baseline, optimized []Metrics) {
+	baseCount, baseLatency := meanSyscalls(baseline)
+	optCount, optLatency := meanSyscalls(optimized)
+
+	seen := make(map[string]bool)
+	for _, counts := range []map[string]float64{baseCount, optCount} {
+		for name := range counts {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			c.Syscalls = append(c.Syscalls, SyscallDelta{
+				Name:               name,
+				BaselineCount:      baseCount[name],
+				OptimizedCount:     optCount[name],
+				CountDelta:         optCount[name] - baseCount[name],
+				BaselineLatencyUs:  baseLatency[name],
+				OptimizedLatencyUs: optLatency[name],
+				LatencyDeltaUs:     optLatency[name] - baseLatency[name],
+			})
+		}
+	}
+
+	sort.Slice(c.Syscalls, func(i, j int) bool {
+		a, b := c.Syscalls[i], c.Syscalls[j]
+		if math.Abs(a.LatencyDeltaUs) != math.Abs(b.LatencyDeltaUs) {
+			return math.Abs(a.LatencyDeltaUs) > math.Abs(b.LatencyDeltaUs)
+		}
+		if math.Abs(a.CountDelta) != math.Abs(b.CountDelta) {
+			return math.Abs(a.CountDelta) > math.Abs(b.CountDelta)
+		}
+		return a.Name < b.Name
+	})
+	if len(c.Syscalls) > maxCompareSyscalls {
+		c.Syscalls = c.Syscalls[:maxCompareSyscalls]
+	}
+}
+
+// meanSyscalls returns the mean count and total latency per run of every
+// syscall.
+func meanSyscalls(metrics []Metrics) (map[string]float64, map[string]float64) {
+	counts := make(map[string]float64)
+	latencies := make(map[string]float64)
+	n := float64(len(metrics))
+	for _, m := range metrics {
+		for name, count := range m.TopSyscalls {
+			counts[name] += float64(count) / n
+		}
+		for name, us := range m.SyscallLatencyUs {
+			latencies[name] += us / n
+		}
+	}
+	return counts, latencies
+}
@@ -0,0 +1,433 @@
+package ebpf
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/rlimit"
+)
+
+// nativeTracer collects runqueue and block I/O latency histograms, off-CPU
+// stacks and syscall statistics in-process, with eBPF programs attached to raw tracepoints.
+// It needs no bcc install and reads the results straight from the BPF maps.
+type nativeTracer struct {
+	layout *kernelLayout
+
+	// filter holds the cgroup ID events are limited to (0 = whole system)
+	filter    *ebpf.Map
+	runqStart *ebpf.Map
+	runqHist  *ebpf.Map
+	bioStart  *ebpf.Map
+	bioHist   *ebpf.Map
+	offStart  *ebpf.Map
+	offStacks *ebpf.Map
+	offTime   *ebpf.Map
+	comms     *ebpf.Map
+	sysStart  *ebpf.Map
+	sysTotals *ebpf.Map
+
+	symbols *symbolizer
+	// watchDone stops the goroutine capturing process mappings
+	watchDone chan struct{}
+	watchWait chan struct{}
+
+	progs []tracedProgram
+	links []link.Link
+}
+
+// tracedProgram is a loaded program and the raw tracepoint it attaches to.
+type tracedProgram struct {
+	tracepoint string
+	prog       *ebpf.Program
+}
+
+// offKey mirrors the offTime map key built by offcpuProgram.
+type offKey struct {
+	KernelStack int32
+	UserStack   int32
+	Tgid        uint32
+	_           uint32
+}
+
+func newNativeTracer() (*nativeTracer, error) {
+	// Kernels before 5.11 account BPF memory against RLIMIT_MEMLOCK
+	_ = rlimit.RemoveMemlock()
+
+	layout, err := loadKernelLayout()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &nativeTracer{layout: layout, symbols: newSymbolizer()}
+	if err := t.load(); err != nil {
+		t.close()
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *nativeTracer) load() error {
+	var err error
+	if t.filter, err = newMap(ebpf.Array, 4, 8, 1); err != nil {
+		return err
+	}
+	if t.runqStart, err = newMap(ebpf.Hash, 4, 8, 10240); err != nil {
+		return err
+	}
+	if t.runqHist, err = newMap(ebpf.Array, 4, 8, histSlots); err != nil {
+		return err
+	}
+	if t.bioStart, err = newMap(ebpf.Hash, 8, 8, 10240); err != nil {
+		return err
+	}
+	if t.bioHist, err = newMap(ebpf.Array, 4, 8, histSlots); err != nil {
+		return err
+	}
+	if t.offStart, err = newMap(ebpf.Hash, 4, 24, 10240); err != nil {
+		return err
+	}
+	if t.offStacks, err = newMap(ebpf.StackTrace, 4, maxStackDepth*8, 16384); err != nil {
+		return err
+	}
+	if t.offTime, err = newMap(ebpf.Hash, 16, 8, 16384); err != nil {
+		return err
+	}
+	if t.comms, err = newMap(ebpf.Hash, 4, 16, 10240); err != nil {
+		return err
+	}
+	if t.sysStart, err = newMap(ebpf.Hash, 4, 16, 10240); err != nil {
+		return err
+	}
+	if t.sysTotals, err = newMap(ebpf.Array, 4, 16, syscallSlots); err != nil {
+		return err
+	}
+
+	wakeup := wakeupProgram(t.layout, t.filter, t.runqStart)
+	type program struct {
+		name, tracepoint string
+		insns            asm.Instructions
+	}
+	progs := []program{
+		{"sched_wakeup", "sched_wakeup", wakeup},
+		{"sched_wakeup_new", "sched_wakeup_new", wakeup},
+		{"sched_switch", "sched_switch", switchProgram(t.layout, t.filter, t.runqStart, t.runqHist)},
+		{"offcpu", "sched_switch", offcpuProgram(t.layout, t.filter, t.offStart, t.offStacks, t.offTime, t.comms)},
+		{"sys_enter", "sys_enter", syscallEnterProgram(t.filter, t.sysStart)},
+		{"sys_exit", "sys_exit", syscallExitProgram(t.sysStart, t.sysTotals)},
+	}
+
+	// Block I/O is optional: some kernels (or containers) lack the tracepoints
+	issueArg, issueErr := requestArg(t.layout.rqIssueArgs, 1)
+	completeArg, completeErr := requestArg(t.layout.rqCompleteArgs, 3)
+	if issueErr == nil && completeErr == nil {
+		progs = append(progs,
+			program{"block_rq_issue", "block_rq_issue", requestProgram(issueArg, t.filter, t.bioStart, nil, false)},
+			program{"block_rq_complete", "block_rq_complete", requestProgram(completeArg, t.filter, t.bioStart, t.bioHist, true)},
+		)
+	}
+
+	for _, p := range progs {
+		prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+			Name:         truncateName(p.name),
+			Type:         ebpf.RawTracepoint,
+			Instructions: p.insns,
+			License:      "GPL",
+		})
+		if err != nil {
+			return fmt.Errorf("failed to load %s program: %w", p.name, err)
+		}
+		t.progs = append(t.progs, tracedProgram{tracepoint: p.tracepoint, prog: prog})
+	}
+	return nil
+}
+
+// start clears the maps and attaches the programs, limited to the tasks of
+// the given cgroup (0 traces the whole system).
+func (t *nativeTracer) start(cgroupID uint64) error {
+	t.reset()
+	if err := t.filter.Put(uint32(0), cgroupID); err != nil {
+		return fmt.Errorf("failed to set cgroup filter: %w", err)
+	}
+	for _, p := range t.progs {
+		l, err := link.AttachRawTracepoint(link.RawTracepointOptions{Name: p.tracepoint, Program: p.prog})
+		if err != nil {
+			t.detach()
+			return fmt.Errorf("failed to attach %s: %w", p.tracepoint, err)
+		}
+		t.links = append(t.links, l)
+	}
+
+	t.watchDone = make(chan struct{})
+	t.watchWait = make(chan struct{})
+	go t.watch(t.watchDone, t.watchWait)
+	return nil
+}
+
+// watch captures the mappings of every traced process while it is alive,
+// so that user stacks can be symbolized after it exits. Processes living
+// less than the polling interval keep unsymbolized user frames.
+func (t *nativeTracer) watch(done, wait chan struct{}) {
+	defer close(wait)
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		var tgid uint32
+		var comm [16]byte
+		iter := t.comms.Iterate()
+		for iter.Next(&tgid, &comm) {
+			t.symbols.snapshot(tgid)
+		}
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// stop detaches the programs and copies the histograms, the off-CPU stacks
+// and the syscall statistics into m.
+func (t *nativeTracer) stop(m *Metrics) error {
+	t.detach()
+
+	var err error
+	if m.RunqlatHistogram, err = readHistogram(t.runqHist); err != nil {
+		return err
+	}
+	m.RunqueueLatencyUs = calculateAvgFromHistogram(m.RunqlatHistogram)
+
+	if m.BiolatHistogram, err = readHistogram(t.bioHist); err != nil {
+		return err
+	}
+	m.IoLatencyUs = calculateAvgFromHistogram(m.BiolatHistogram)
+
+	m.OffCpuTimeMs, m.OffCpuTopStacks = topStacks(t.readOffCpu(), maxRunStacks)
+
+	return t.readSyscalls(m)
+}
+
+// readSyscalls fills the syscall counts and total latencies of m.
+func (t *nativeTracer) readSyscalls(m *Metrics) error {
+	var nr uint32
+	var total struct{ Count, Ns uint64 }
+	iter := t.sysTotals.Iterate()
+	for iter.Next(&nr, &total) {
+		if total.Count == 0 {
+			continue
+		}
+		name := syscallName(nr)
+		m.TopSyscalls[name] = int64(total.Count)
+		m.SyscallLatencyUs[name] = float64(total.Ns) / 1000
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to read syscalls: %w", err)
+	}
+	return nil
+}
+
+func syscallName(nr uint32) string {
+	if name, ok := syscallNames[nr]; ok {
+		return name
+	}
+	return fmt.Sprintf("syscall_%d", nr)
+}
+
+// readOffCpu returns the off-CPU microseconds per symbolized stack, folded
+// like bcc's offcputime -f -d: "comm;user frames;-;kernel frames", outermost
+// frame first.
+func (t *nativeTracer) readOffCpu() map[string]uint64 {
+	comms := make(map[uint32]string)
+	var tgid uint32
+	var comm [16]byte
+	iter := t.comms.Iterate()
+	for iter.Next(&tgid, &comm) {
+		comms[tgid] = strings.TrimRight(string(comm[:]), "\x00")
+	}
+
+	folded := make(map[string]uint64)
+	var key offKey
+	var us uint64
+	iter = t.offTime.Iterate()
+	for iter.Next(&key, &us) {
+		name, ok := comms[key.Tgid]
+		if !ok {
+			name = fmt.Sprintf("[%d]", key.Tgid)
+		}
+		frames := []string{name}
+		for _, addr := range t.stackFrames(key.UserStack) {
+			frames = append(frames, t.symbols.userFrame(key.Tgid, addr))
+		}
+		frames = append(frames, "-")
+		var kernel []string
+		for _, addr := range t.stackFrames(key.KernelStack) {
+			name := t.symbols.kernelFrame(addr)
+			// Leave out the program and tracing frames above the scheduler
+			if isTracingFrame(name) {
+				kernel = nil
+				continue
+			}
+			kernel = append(kernel, name)
+		}
+		frames = append(frames, kernel...)
+		reverse(frames[1:])
+		folded[strings.Join(frames, ";")] += us
+	}
+	return folded
+}
+
+// stackFrames returns the addresses of a stack, innermost first, or nil for
+// a missing stack (kernel threads have no user stack).
+func (t *nativeTracer) stackFrames(id int32) []uint64 {
+	if id < 0 {
+		return nil
+	}
+	var addrs [maxStackDepth]uint64
+	if err := t.offStacks.Lookup(uint32(id), &addrs); err != nil {
+		return nil
+	}
+	var frames []uint64
+	for _, addr := range addrs {
+		if addr == 0 {
+			break
+		}
+		frames = append(frames, addr)
+	}
+	return frames
+}
+
+func isTracingFrame(name string) bool {
+	for _, prefix := range []string{"bpf_", "__bpf_", "__traceiter_"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// reverse reverses frames in place. A "-" separator keeps the user frames
+// before the kernel frames.
+func reverse(frames []string) {
+	sep := -1
+	for i, f := range frames {
+		if f == "-" {
+			sep = i
+		}
+	}
+	if sep < 0 {
+		slices.Reverse(frames)
+		return
+	}
+	slices.Reverse(frames[:sep])
+	slices.Reverse(frames[sep+1:])
+}
+
+func (t *nativeTracer) attached() bool {
+	return len(t.links) > 0
+}
+
+func (t *nativeTracer) detach() {
+	if t.watchDone != nil {
+		close(t.watchDone)
+		<-t.watchWait
+		t.watchDone = nil
+	}
+	for _, l := range t.links {
+		l.Close()
+	}
+	t.links = nil
+}
+
+// reset empties the timestamp and stack maps and zeroes the histograms.
+func (t *nativeTracer) reset() {
+	for _, m := range []*ebpf.Map{t.runqStart, t.bioStart, t.offStart, t.offStacks, t.offTime, t.comms, t.sysStart} {
+		clearMap(m)
+	}
+	var zero [2]uint64
+	for nr := uint32(0); nr < syscallSlots; nr++ {
+		t.sysTotals.Put(nr, zero)
+	}
+	t.symbols.reset()
+	for _, m := range []*ebpf.Map{t.runqHist, t.bioHist} {
+		for slot := uint32(0); slot < histSlots; slot++ {
+			m.Put(slot, uint64(0))
+		}
+	}
+}
+
+func (t *nativeTracer) close() {
+	t.detach()
+	for _, p := range t.progs {
+		p.prog.Close()
+	}
+	for _, m := range []*ebpf.Map{t.filter, t.runqStart, t.runqHist, t.bioStart, t.bioHist, t.offStart, t.offStacks, t.offTime, t.comms, t.sysStart, t.sysTotals} {
+		if m != nil {
+			m.Close()
+		}
+	}
+}
+
+func newMap(typ ebpf.MapType, keySize, valueSize, maxEntries uint32) (*ebpf.Map, error) {
+	m, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       typ,
+		KeySize:    keySize,
+		ValueSize:  valueSize,
+		MaxEntries: maxEntries,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create BPF map: %w", err)
+	}
+	return m, nil
+}
+
+// clearMap deletes every key of a hash map.
+func clearMap(m *ebpf.Map) {
+	var keys [][]byte
+	var key []byte
+	iter := m.Iterate()
+	var value []byte
+	for iter.Next(&key, &value) {
+		keys = append(keys, append([]byte(nil), key...))
+	}
+	for _, k := range keys {
+		if err := m.Delete(k); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return
+		}
+	}
+}
+
+// readHistogram converts a log2 histogram map into the bucket format of the
+// bcc tools ("low-high" in microseconds), keeping non-empty buckets only.
+func readHistogram(m *ebpf.Map) (map[string]int64, error) {
+	hist := make(map[string]int64)
+	for slot := uint32(0); slot < histSlots; slot++ {
+		var count uint64
+		if err := m.Lookup(slot, &count); err != nil {
+			return nil, fmt.Errorf("failed to read histogram: %w", err)
+		}
+		if count == 0 {
+			continue
+		}
+		low := uint64(1) << slot
+		if slot == 0 {
+			low = 0
+		}
+		high := uint64(1)<<(slot+1) - 1
+		hist[strconv.FormatUint(low, 10)+"-"+strconv.FormatUint(high, 10)] = int64(count)
+	}
+	return hist, nil
+}
+
+// truncateName fits a program name in the kernel's 15 character limit.
+func truncateName(name string) string {
+	if len(name) > 15 {
+		return name[:15]
+	}
+	return name
+}
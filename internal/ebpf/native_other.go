@@ -0,0 +1,17 @@
+//go:build !linux
+
+package ebpf
+
+import "errors"
+
+// nativeTracer is only implemented on Linux.
+type nativeTracer struct{}
+
+func newNativeTracer() (*nativeTracer, error) {
+	return nil, errors.New("native eBPF collection requires Linux")
+}
+
+func (t *nativeTracer) start(cgroupID uint64) error { return nil }
+func (t *nativeTracer) stop(m *Metrics) error       { return nil }
+func (t *nativeTracer) close()                      {}
+func (t *nativeTracer) attached() bool              { return false }
@@ -0,0 +1,187 @@
+package ebpf
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Number of off-CPU stacks kept per run and shown in comparisons.
+const (
+	maxRunStacks     = 20
+	maxCompareStacks = 10
+)
+
+// StackDelta compares the off-CPU time of one stack, as a mean per run.
+type StackDelta struct {
+	Stack            string  `json:"stack"`
+	BaselineMs       float64 `json:"baseline_ms"`
+	OptimizedMs      float64 `json:"optimized_ms"`
+	BaselinePercent  float64 `json:"baseline_percent"`
+	OptimizedPercent float64 `json:"optimized_percent"`
+	DeltaMs          float64 `json:"delta_ms"`
+}
+
+// compareStacks matches the off-CPU stacks of the baseline and optimized
+// runs. Stacks are sorted by the size of their change, so the waits the
+// optimization removed (or added) come first.
+func compareStacks(c *Comparison, baseline, optimized []Metrics) {
+	labels := make(stackLabels)
+	baseStacks, baseTotal := meanStacks(baseline, labels)
+	optStacks, optTotal := meanStacks(optimized, labels)
+	c.OffCpuBaselineMs, c.OffCpuOptimizedMs = baseTotal, optTotal
+
+	seen := make(map[string]bool)
+	for _, stacks := range []map[string]float64{baseStacks, optStacks} {
+		for key := range stacks {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			d := StackDelta{
+				Stack:       labels[key].stack,
+				BaselineMs:  baseStacks[key],
+				OptimizedMs: optStacks[key],
+			}
+			d.DeltaMs = d.OptimizedMs - d.BaselineMs
+			if baseTotal > 0 {
+				d.BaselinePercent = d.BaselineMs / baseTotal * 100
+			}
+			if optTotal > 0 {
+				d.OptimizedPercent = d.OptimizedMs / optTotal * 100
+			}
+			c.OffCpuStacks = append(c.OffCpuStacks, d)
+		}
+	}
+
+	sort.Slice(c.OffCpuStacks, func(i, j int) bool {
+		a, b := c.OffCpuStacks[i], c.OffCpuStacks[j]
+		if math.Abs(a.DeltaMs) != math.Abs(b.DeltaMs) {
+			return math.Abs(a.DeltaMs) > math.Abs(b.DeltaMs)
+		}
+		return a.Stack < b.Stack
+	})
+	if len(c.OffCpuStacks) > maxCompareStacks {
+		c.OffCpuStacks = c.OffCpuStacks[:maxCompareStacks]
+	}
+}
+
+// stackLabels holds the stack shown for each matching key: the heaviest
+// one, preferring stacks whose user frames were all resolved.
+type stackLabels map[string]stackLabel
+
+type stackLabel struct {
+	stack    string
+	resolved bool
+	timeMs   float64
+}
+
+func (l stackLabels) offer(key, stack string, timeMs float64) {
+	resolved := !strings.Contains(stack, unknownFrame)
+	cur, ok := l[key]
+	if !ok || (resolved && !cur.resolved) || (resolved == cur.resolved && timeMs > cur.timeMs) {
+		l[key] = stackLabel{stack: stack, resolved: resolved, timeMs: timeMs}
+	}
+}
+
+// unknownFrame is a user frame that could not be symbolized, e.g. of a
+// process that exited before its mappings were read.
+const unknownFrame = "[unknown]"
+
+// meanStacks returns the mean off-CPU time per run of every stack, by
+// matching key, and the mean total. Stacks are matched on their kernel
+// frames: the process name usually differs between the scenarios' scripts,
+// and the user frames of short-lived processes may not resolve, which would
+// split the same wait into unrelated rows.
+func meanStacks(metrics []Metrics, labels stackLabels) (map[string]float64, float64) {
+	stacks := make(map[string]float64)
+	if len(metrics) == 0 {
+		return stacks, 0
+	}
+	var total float64
+	n := float64(len(metrics))
+	for _, m := range metrics {
+		total += m.OffCpuTimeMs / n
+		for _, s := range m.OffCpuTopStacks {
+			stack := s.Stack
+			if i := strings.IndexByte(stack, ';'); i >= 0 {
+				stack = stack[i+1:]
+			}
+			key := stack
+			if i := strings.Index(stack, "-;"); i == 0 || (i > 0 && stack[i-1] == ';') {
+				key = stack[i+2:]
+			}
+			stacks[key] += s.TimeMs / n
+			labels.offer(key, stack, s.TimeMs)
+		}
+	}
+	return stacks, total
+}
+
+// topStacks turns off-CPU microseconds per folded stack into the total time
+// and the heaviest stacks with their share of it.
+func topStacks(folded map[string]uint64, n int) (float64, []StackTrace) {
+	var totalUs uint64
+	for _, us := range folded {
+		totalUs += us
+	}
+	if totalUs == 0 {
+		return 0, nil
+	}
+
+	stacks := make([]StackTrace, 0, len(folded))
+	for stack, us := range folded {
+		stacks = append(stacks, StackTrace{
+			Stack:   stack,
+			TimeMs:  float64(us) / 1000,
+			Percent: float64(us) / float64(totalUs) * 100,
+		})
+	}
+	sort.Slice(stacks, func(i, j int) bool {
+		if stacks[i].TimeMs != stacks[j].TimeMs {
+			return stacks[i].TimeMs > stacks[j].TimeMs
+		}
+		return stacks[i].Stack < stacks[j].Stack
+	})
+	if len(stacks) > n {
+		stacks = stacks[:n]
+	}
+	return float64(totalUs) / 1000, stacks
+}
+
+// parseFolded reads the folded output of bcc's offcputime -f
+// ("comm;frame;...;frame value"), in microseconds.
+func parseFolded(output string) map[string]uint64 {
+	folded := make(map[string]uint64)
+	for _, line := range strings.Split(output, "\n") {
+		i := strings.LastIndexByte(line, ' ')
+		if i <= 0 {
+			continue
+		}
+		us, err := strconv.ParseUint(line[i+1:], 10, 64)
+		if err != nil {
+			continue
+		}
+		folded[line[:i]] += us
+	}
+	return folded
+}
+
+// ShortStack renders the innermost frames of a folded stack, innermost
+// first. The scheduler frames every off-CPU stack ends with are skipped.
+func ShortStack(stack string, depth int) string {
+	frames := strings.Split(stack, ";")
+	i := len(frames) - 1
+	for i > 0 && strings.Contains(frames[i], "schedule") {
+		i--
+	}
+	var inner []string
+	for ; i >= 0 && len(inner) < depth; i-- {
+		if frames[i] == "-" {
+			continue
+		}
+		inner = append(inner, frames[i])
+	}
+	return strings.Join(inner, " ← ")
+}
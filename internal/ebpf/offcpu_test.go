@@ -0,0 +1,23 @@
+package ebpf
+
+import "testing"
+
+func TestCompareStacksMatchesUnresolvedFrames(t *testing.T) {
+	baseline := []Metrics{{OffCpuTimeMs: 26, OffCpuTopStacks: []StackTrace{
+		{Stack: "a.sh;main;wait4;-;do_syscall_64;kernel_wait4;do_wait;schedule", TimeMs: 21},
+		{Stack: "a.sh;main;read;-;ksys_read;pipe_read;schedule", TimeMs: 5},
+	}}}
+	optimized := []Metrics{{OffCpuTimeMs: 12, OffCpuTopStacks: []StackTrace{
+		{Stack: "b.sh;[unknown];-;do_syscall_64;kernel_wait4;do_wait;schedule", TimeMs: 12},
+	}}}
+
+	var c Comparison
+	compareStacks(&c, baseline, optimized)
+	if len(c.OffCpuStacks) != 2 {
+		t.Fatalf("compareStacks() gave %d stacks, want 2: %+v", len(c.OffCpuStacks), c.OffCpuStacks)
+	}
+	wait := c.OffCpuStacks[0]
+	if wait.Stack != "main;wait4;-;do_syscall_64;kernel_wait4;do_wait;schedule" || wait.BaselineMs != 21 || wait.OptimizedMs != 12 || wait.DeltaMs != -9 {
+		t.Errorf("wait stack = %+v, want the resolved stack, 21ms -> 12ms", wait)
+	}
+}
@@ -0,0 +1,401 @@
+package ebpf
+
+import (
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+)
+
+// histSlots is the number of log2 buckets of the latency histograms, the
+// same layout as bcc's runqlat and biolatency (slot i holds 2^i..2^(i+1)-1).
+const histSlots = 40
+
+// Stack slots used by the programs, relative to the frame pointer.
+const (
+	stackKey   = -8
+	stackValue = -16
+	stackSlot  = -20
+	// offcpuProgram: the offStart value {ts, kstack, ustack, tgid, pad},
+	// the offTime key {kstack, ustack, tgid, pad} and the task's comm
+	stackOffStart = -40
+	stackOffKey   = -56
+	stackComm     = -72
+)
+
+// maxStackDepth is the number of frames kept per stack, the kernel's default
+// perf_event_max_stack.
+const maxStackDepth = 127
+
+// flagUserStack is BPF_F_USER_STACK for bpf_get_stackid.
+const flagUserStack = 1 << 8
+
+// mapPtr loads the address of m into dst.
+func mapPtr(dst asm.Register, m *ebpf.Map) asm.Instruction {
+	ins := asm.LoadMapPtr(dst, 0)
+	if err := ins.AssociateMap(m); err != nil {
+		panic(err)
+	}
+	return ins
+}
+
+// readKernel copies size bytes at src+off to the stack slot at stackOff with
+// bpf_probe_read_kernel and loads them into dst. It clobbers R0-R5, so src
+// must be a callee-saved register (R6-R9).
+func readKernel(dst, src asm.Register, off int32, size asm.Size, stackOff int16) asm.Instructions {
+	return asm.Instructions{
+		asm.Mov.Reg(asm.R1, asm.RFP),
+		asm.Add.Imm(asm.R1, int32(stackOff)),
+		asm.Mov.Imm(asm.R2, int32(size.Sizeof())),
+		asm.Mov.Reg(asm.R3, src),
+		asm.Add.Imm(asm.R3, off),
+		asm.FnProbeReadKernel.Call(),
+		asm.LoadMem(dst, asm.RFP, stackOff, size),
+	}
+}
+
+// storeTimestamp emits m[key at stackKey] = bpf_ktime_get_ns().
+func storeTimestamp(m *ebpf.Map) asm.Instructions {
+	return asm.Instructions{
+		asm.FnKtimeGetNs.Call(),
+		asm.StoreMem(asm.RFP, stackValue, asm.R0, asm.DWord),
+		mapPtr(asm.R1, m),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackKey),
+		asm.Mov.Reg(asm.R3, asm.RFP),
+		asm.Add.Imm(asm.R3, stackValue),
+		asm.Mov.Imm(asm.R4, 0),
+		asm.FnMapUpdateElem.Call(),
+	}
+}
+
+// elapsedSince looks up the timestamp stored under the key at stackKey,
+// deletes it and leaves the elapsed time in microseconds in R7. It jumps to
+// exit when there is no timestamp.
+func elapsedSince(m *ebpf.Map, exit string) asm.Instructions {
+	return asm.Instructions{
+		mapPtr(asm.R1, m),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackKey),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, exit),
+		asm.LoadMem(asm.R7, asm.R0, 0, asm.DWord),
+		asm.FnKtimeGetNs.Call(),
+		asm.Sub.Reg(asm.R0, asm.R7),
+		asm.Div.Imm(asm.R0, 1000),
+		asm.Mov.Reg(asm.R7, asm.R0),
+		mapPtr(asm.R1, m),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackKey),
+		asm.FnMapDeleteElem.Call(),
+	}
+}
+
+// histIncrement emits hist[log2(R7)]++ on an array of u64 counters.
+func histIncrement(hist *ebpf.Map, exit string) asm.Instructions {
+	done := exit + "_slot"
+	insns := asm.Instructions{asm.Mov.Imm(asm.R8, 0)}
+	for i := 1; i < histSlots; i++ {
+		insns = append(insns,
+			asm.LoadImm(asm.R2, int64(1)<<i, asm.DWord),
+			asm.JLT.Reg(asm.R7, asm.R2, done),
+			asm.Mov.Imm(asm.R8, int32(i)),
+		)
+	}
+	return append(insns,
+		asm.StoreMem(asm.RFP, stackSlot, asm.R8, asm.Word).WithSymbol(done),
+		mapPtr(asm.R1, hist),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackSlot),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, exit),
+		asm.Mov.Imm(asm.R1, 1),
+		asm.StoreXAdd(asm.R0, asm.R1, asm.DWord),
+	)
+}
+
+// currentCgroupID loads the cgroup v2 ID of the current task into R8.
+func currentCgroupID() asm.Instructions {
+	return asm.Instructions{
+		asm.FnGetCurrentCgroupId.Call(),
+		asm.Mov.Reg(asm.R8, asm.R0),
+	}
+}
+
+// taskCgroupID loads the cgroup v2 ID of the task pointed to by src into R8.
+func taskCgroupID(l *kernelLayout, src asm.Register) asm.Instructions {
+	insns := readKernel(asm.R8, src, l.taskCgroups, asm.DWord, stackValue)
+	insns = append(insns, readKernel(asm.R8, asm.R8, l.cssSetDflCgrp, asm.DWord, stackValue)...)
+	insns = append(insns, readKernel(asm.R8, asm.R8, l.cgroupKn, asm.DWord, stackValue)...)
+	return append(insns, readKernel(asm.R8, asm.R8, l.kernfsID, asm.DWord, stackValue)...)
+}
+
+// cgroupFilter jumps to skip unless the cgroup ID in R8 is the one set in
+// the filter map. A zero filter lets every task through.
+func cgroupFilter(filter *ebpf.Map, skip string) asm.Instructions {
+	pass := skip + "_pass"
+	return asm.Instructions{
+		asm.StoreImm(asm.RFP, stackSlot, 0, asm.Word),
+		mapPtr(asm.R1, filter),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackSlot),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, skip),
+		asm.LoadMem(asm.R1, asm.R0, 0, asm.DWord),
+		asm.JEq.Imm(asm.R1, 0, pass),
+		asm.JNE.Reg(asm.R1, asm.R8, skip),
+		asm.Mov.Imm(asm.R0, 0).WithSymbol(pass),
+	}
+}
+
+// exitInsns ends a program, under the given label.
+func exitInsns(label string) asm.Instructions {
+	return asm.Instructions{
+		asm.Mov.Imm(asm.R0, 0).WithSymbol(label),
+		asm.Return(),
+	}
+}
+
+// wakeupProgram records when a task of the traced cgroup becomes runnable
+// (sched_wakeup and sched_wakeup_new, whose first argument is the task).
+func wakeupProgram(l *kernelLayout, filter, start *ebpf.Map) asm.Instructions {
+	insns := asm.Instructions{
+		asm.Mov.Reg(asm.R6, asm.R1),
+		asm.LoadMem(asm.R9, asm.R6, 0, asm.DWord),
+	}
+	insns = append(insns, readKernel(asm.R7, asm.R9, l.taskPid, asm.Word, stackKey)...)
+	insns = append(insns, asm.JEq.Imm(asm.R7, 0, "exit"))
+	// The woken task is not the current one: follow its cgroup pointers
+	insns = append(insns, taskCgroupID(l, asm.R9)...)
+	insns = append(insns, cgroupFilter(filter, "exit")...)
+	insns = append(insns, storeTimestamp(start)...)
+	return append(insns, exitInsns("exit")...)
+}
+
+// switchProgram measures runqueue latency on sched_switch: a preempted task
+// of the traced cgroup goes back on the runqueue, and the next task's wait
+// since its wakeup is added to the histogram. Only traced tasks have a
+// timestamp, so the next task needs no filtering.
+func switchProgram(l *kernelLayout, filter, start, hist *ebpf.Map) asm.Instructions {
+	insns := asm.Instructions{
+		asm.Mov.Reg(asm.R6, asm.R1),
+		// args: preempt, prev, next
+		asm.LoadMem(asm.R9, asm.R6, 8, asm.DWord),
+	}
+
+	// A prev task still TASK_RUNNING was preempted and waits again
+	insns = append(insns, readKernel(asm.R7, asm.R9, l.taskState, asm.Word, stackKey)...)
+	insns = append(insns, asm.JNE.Imm(asm.R7, 0, "next"))
+	insns = append(insns, readKernel(asm.R7, asm.R9, l.taskPid, asm.Word, stackKey)...)
+	insns = append(insns, asm.JEq.Imm(asm.R7, 0, "next"))
+	// The tracepoint runs in the context of prev
+	insns = append(insns, currentCgroupID()...)
+	insns = append(insns, cgroupFilter(filter, "next")...)
+	insns = append(insns, storeTimestamp(start)...)
+
+	insns = append(insns, asm.LoadMem(asm.R9, asm.R6, 16, asm.DWord).WithSymbol("next"))
+	insns = append(insns, readKernel(asm.R7, asm.R9, l.taskPid, asm.Word, stackKey)...)
+	insns = append(insns, elapsedSince(start, "exit")...)
+	insns = append(insns, histIncrement(hist, "exit")...)
+	return append(insns, exitInsns("exit")...)
+}
+
+// offcpuProgram measures off-CPU time on sched_switch. A blocked prev task of
+// the traced cgroup (one no longer TASK_RUNNING) records when it left the
+// CPU along with its kernel and user stacks; when a task with such a record
+// is switched back in, the time it spent off-CPU is added to the total of
+// its stack pair. The process names go to the comms map, keyed by tgid.
+func offcpuProgram(l *kernelLayout, filter, start, stacks, totals, comms *ebpf.Map) asm.Instructions {
+	insns := asm.Instructions{
+		asm.Mov.Reg(asm.R6, asm.R1),
+		asm.LoadMem(asm.R9, asm.R6, 8, asm.DWord),
+	}
+
+	// Preempted tasks are runqueue latency, not blocking
+	insns = append(insns, readKernel(asm.R7, asm.R9, l.taskState, asm.Word, stackKey)...)
+	insns = append(insns, asm.JEq.Imm(asm.R7, 0, "next"))
+	insns = append(insns, readKernel(asm.R7, asm.R9, l.taskPid, asm.Word, stackKey)...)
+	insns = append(insns, asm.JEq.Imm(asm.R7, 0, "next"))
+	insns = append(insns, currentCgroupID()...)
+	insns = append(insns, cgroupFilter(filter, "next")...)
+	insns = append(insns,
+		asm.Mov.Reg(asm.R1, asm.R6),
+		mapPtr(asm.R2, stacks),
+		asm.Mov.Imm(asm.R3, 0),
+		asm.FnGetStackid.Call(),
+		asm.StoreMem(asm.RFP, stackOffStart+8, asm.R0, asm.Word),
+		asm.Mov.Reg(asm.R1, asm.R6),
+		mapPtr(asm.R2, stacks),
+		asm.Mov.Imm(asm.R3, flagUserStack),
+		asm.FnGetStackid.Call(),
+		asm.StoreMem(asm.RFP, stackOffStart+12, asm.R0, asm.Word),
+		asm.FnGetCurrentPidTgid.Call(),
+		asm.RSh.Imm(asm.R0, 32),
+		asm.StoreMem(asm.RFP, stackOffStart+16, asm.R0, asm.Word),
+		asm.StoreImm(asm.RFP, stackOffStart+20, 0, asm.Word),
+		asm.FnKtimeGetNs.Call(),
+		asm.StoreMem(asm.RFP, stackOffStart, asm.R0, asm.DWord),
+		mapPtr(asm.R1, start),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackKey),
+		asm.Mov.Reg(asm.R3, asm.RFP),
+		asm.Add.Imm(asm.R3, stackOffStart),
+		asm.Mov.Imm(asm.R4, 0),
+		asm.FnMapUpdateElem.Call(),
+		// comms[tgid] = comm
+		asm.Mov.Reg(asm.R1, asm.RFP),
+		asm.Add.Imm(asm.R1, stackComm),
+		asm.Mov.Imm(asm.R2, 16),
+		asm.FnGetCurrentComm.Call(),
+		mapPtr(asm.R1, comms),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackOffStart+16),
+		asm.Mov.Reg(asm.R3, asm.RFP),
+		asm.Add.Imm(asm.R3, stackComm),
+		asm.Mov.Imm(asm.R4, 0),
+		asm.FnMapUpdateElem.Call(),
+	)
+
+	insns = append(insns, asm.LoadMem(asm.R9, asm.R6, 16, asm.DWord).WithSymbol("next"))
+	insns = append(insns, readKernel(asm.R7, asm.R9, l.taskPid, asm.Word, stackKey)...)
+	insns = append(insns,
+		mapPtr(asm.R1, start),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackKey),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, "exit"),
+		asm.Mov.Reg(asm.R7, asm.R0),
+		// Build the offTime key from the record
+		asm.LoadMem(asm.R1, asm.R7, 8, asm.Word),
+		asm.StoreMem(asm.RFP, stackOffKey, asm.R1, asm.Word),
+		asm.LoadMem(asm.R1, asm.R7, 12, asm.Word),
+		asm.StoreMem(asm.RFP, stackOffKey+4, asm.R1, asm.Word),
+		asm.LoadMem(asm.R1, asm.R7, 16, asm.Word),
+		asm.StoreMem(asm.RFP, stackOffKey+8, asm.R1, asm.Word),
+		asm.StoreImm(asm.RFP, stackOffKey+12, 0, asm.Word),
+		// Elapsed microseconds in R8
+		asm.LoadMem(asm.R8, asm.R7, 0, asm.DWord),
+		asm.FnKtimeGetNs.Call(),
+		asm.Sub.Reg(asm.R0, asm.R8),
+		asm.Div.Imm(asm.R0, 1000),
+		asm.Mov.Reg(asm.R8, asm.R0),
+		asm.StoreMem(asm.RFP, stackValue, asm.R8, asm.DWord),
+		mapPtr(asm.R1, start),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackKey),
+		asm.FnMapDeleteElem.Call(),
+		// totals[key] += elapsed
+		mapPtr(asm.R1, totals),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackOffKey),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, "insert"),
+		asm.StoreXAdd(asm.R0, asm.R8, asm.DWord),
+		asm.Ja.Label("exit"),
+		mapPtr(asm.R1, totals).WithSymbol("insert"),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackOffKey),
+		asm.Mov.Reg(asm.R3, asm.RFP),
+		asm.Add.Imm(asm.R3, stackValue),
+		asm.Mov.Imm(asm.R4, 0),
+		asm.FnMapUpdateElem.Call(),
+	)
+	return append(insns, exitInsns("exit")...)
+}
+
+// requestProgram handles block_rq_issue (complete == false) and
+// block_rq_complete, keyed by the request pointer. Requests are attributed
+// to the task that issues them; completions need no filtering.
+func requestProgram(argIndex int, filter, start, hist *ebpf.Map, complete bool) asm.Instructions {
+	insns := asm.Instructions{
+		asm.Mov.Reg(asm.R6, asm.R1),
+		asm.LoadMem(asm.R7, asm.R6, int16(argIndex*8), asm.DWord),
+		asm.StoreMem(asm.RFP, stackKey, asm.R7, asm.DWord),
+	}
+	if complete {
+		insns = append(insns, elapsedSince(start, "exit")...)
+		insns = append(insns, histIncrement(hist, "exit")...)
+	} else {
+		insns = append(insns, currentCgroupID()...)
+		insns = append(insns, cgroupFilter(filter, "exit")...)
+		insns = append(insns, storeTimestamp(start)...)
+	}
+	return append(insns, exitInsns("exit")...)
+}
+
+// requestArg returns the index of the struct request argument of a block
+// tracepoint with n arguments, given its index in the modern (>= 5.11)
+// signature, where the leading request_queue argument was dropped.
+func requestArg(n, modernArgs int) (int, error) {
+	switch n {
+	case modernArgs:
+		return 0, nil
+	case modernArgs + 1:
+		return 1, nil
+	}
+	return 0, fmt.Errorf("unexpected block tracepoint signature (%d args)", n)
+}
+
+// syscallSlots bounds the syscall numbers counted by syscallExitProgram.
+const syscallSlots = 1024
+
+// syscallEnterProgram records the start time and number of the syscalls of
+// the traced cgroup (sys_enter: regs, id), keyed by thread ID.
+func syscallEnterProgram(filter, start *ebpf.Map) asm.Instructions {
+	insns := asm.Instructions{
+		asm.Mov.Reg(asm.R6, asm.R1),
+	}
+	insns = append(insns, currentCgroupID()...)
+	insns = append(insns, cgroupFilter(filter, "exit")...)
+	insns = append(insns,
+		asm.FnGetCurrentPidTgid.Call(),
+		asm.StoreMem(asm.RFP, stackKey, asm.R0, asm.Word),
+		asm.LoadMem(asm.R7, asm.R6, 8, asm.DWord),
+		asm.StoreMem(asm.RFP, stackValue, asm.R7, asm.DWord),
+		asm.FnKtimeGetNs.Call(),
+		asm.StoreMem(asm.RFP, stackValue-8, asm.R0, asm.DWord),
+		mapPtr(asm.R1, start),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackKey),
+		asm.Mov.Reg(asm.R3, asm.RFP),
+		asm.Add.Imm(asm.R3, stackValue-8),
+		asm.Mov.Imm(asm.R4, 0),
+		asm.FnMapUpdateElem.Call(),
+	)
+	return append(insns, exitInsns("exit")...)
+}
+
+// syscallExitProgram adds the duration of a syscall recorded by
+// syscallEnterProgram to the count and total nanoseconds of its number.
+func syscallExitProgram(start, totals *ebpf.Map) asm.Instructions {
+	return append(asm.Instructions{
+		asm.FnGetCurrentPidTgid.Call(),
+		asm.StoreMem(asm.RFP, stackKey, asm.R0, asm.Word),
+		mapPtr(asm.R1, start),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackKey),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, "exit"),
+		// R7 = elapsed ns, R8 = syscall number
+		asm.LoadMem(asm.R7, asm.R0, 0, asm.DWord),
+		asm.LoadMem(asm.R8, asm.R0, 8, asm.DWord),
+		asm.FnKtimeGetNs.Call(),
+		asm.Sub.Reg(asm.R0, asm.R7),
+		asm.Mov.Reg(asm.R7, asm.R0),
+		mapPtr(asm.R1, start),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackKey),
+		asm.FnMapDeleteElem.Call(),
+		asm.JGE.Imm(asm.R8, syscallSlots, "exit"),
+		asm.StoreMem(asm.RFP, stackSlot, asm.R8, asm.Word),
+		mapPtr(asm.R1, totals),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackSlot),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, "exit"),
+		asm.Mov.Imm(asm.R1, 1),
+		asm.StoreXAdd(asm.R0, asm.R1, asm.DWord),
+		asm.Add.Imm(asm.R0, 8),
+		asm.StoreXAdd(asm.R0, asm.R7, asm.DWord),
+	}, exitInsns("exit")...)
+}
@@ -0,0 +1,224 @@
+package ebpf
+
+import (
+	"bufio"
+	"debug/elf"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// symbolTable maps addresses to the name of the enclosing symbol.
+type symbolTable struct {
+	addrs []uint64
+	names []string
+}
+
+func (s *symbolTable) add(addr uint64, name string) {
+	s.addrs = append(s.addrs, addr)
+	s.names = append(s.names, name)
+}
+
+func (s *symbolTable) sort() {
+	sort.Sort(s)
+}
+
+func (s *symbolTable) Len() int           { return len(s.addrs) }
+func (s *symbolTable) Less(i, j int) bool { return s.addrs[i] < s.addrs[j] }
+func (s *symbolTable) Swap(i, j int) {
+	s.addrs[i], s.addrs[j] = s.addrs[j], s.addrs[i]
+	s.names[i], s.names[j] = s.names[j], s.names[i]
+}
+
+// lookup returns the symbol at or below addr, or "" if there is none.
+func (s *symbolTable) lookup(addr uint64) string {
+	i := sort.Search(len(s.addrs), func(i int) bool { return s.addrs[i] > addr })
+	if i == 0 {
+		return ""
+	}
+	return s.names[i-1]
+}
+
+// loadKernelSymbols reads the kernel text symbols from /proc/kallsyms.
+func loadKernelSymbols() (*symbolTable, error) {
+	f, err := os.Open("/proc/kallsyms")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	syms := &symbolTable{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		switch fields[1] {
+		case "t", "T", "w", "W":
+		default:
+			continue
+		}
+		addr, err := strconv.ParseUint(fields[0], 16, 64)
+		if err != nil || addr == 0 {
+			continue
+		}
+		syms.add(addr, fields[2])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if syms.Len() == 0 {
+		return nil, fmt.Errorf("no kernel symbols readable (kptr_restrict?)")
+	}
+	syms.sort()
+	return syms, nil
+}
+
+// mapping is one file-backed executable mapping of a process.
+type mapping struct {
+	start, end, offset uint64
+	path               string
+}
+
+// readMappings returns the executable file mappings of a process, from
+// /proc/<pid>/maps.
+func readMappings(pid uint32) ([]mapping, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/maps", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var maps []mapping
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// start-end perms offset dev inode path
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 || !strings.Contains(fields[1], "x") || !strings.HasPrefix(fields[5], "/") {
+			continue
+		}
+		bounds := strings.SplitN(fields[0], "-", 2)
+		if len(bounds) != 2 {
+			continue
+		}
+		start, err1 := strconv.ParseUint(bounds[0], 16, 64)
+		end, err2 := strconv.ParseUint(bounds[1], 16, 64)
+		offset, err3 := strconv.ParseUint(fields[2], 16, 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		maps = append(maps, mapping{start: start, end: end, offset: offset, path: fields[5]})
+	}
+	return maps, scanner.Err()
+}
+
+// elfSymbols holds the function symbols of an ELF file, along with its
+// loadable segments to turn file offsets into symbol addresses.
+type elfSymbols struct {
+	symbols *symbolTable
+	loads   []elf.ProgHeader
+}
+
+func loadElfSymbols(path string) (*elfSymbols, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	e := &elfSymbols{symbols: &symbolTable{}}
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_LOAD {
+			e.loads = append(e.loads, p.ProgHeader)
+		}
+	}
+	syms, _ := f.Symbols()
+	dynsyms, _ := f.DynamicSymbols()
+	for _, s := range append(syms, dynsyms...) {
+		if elf.ST_TYPE(s.Info) == elf.STT_FUNC && s.Value != 0 {
+			e.symbols.add(s.Value, s.Name)
+		}
+	}
+	e.symbols.sort()
+	return e, nil
+}
+
+// lookup returns the symbol covering a file offset.
+func (e *elfSymbols) lookup(fileOffset uint64) string {
+	for _, p := range e.loads {
+		if fileOffset >= p.Off && fileOffset < p.Off+p.Filesz {
+			return e.symbols.lookup(fileOffset - p.Off + p.Vaddr)
+		}
+	}
+	return ""
+}
+
+// symbolizer turns stack addresses into function names. Process mappings
+// must be captured while the processes are alive; ELF files are read lazily
+// and cached across runs.
+type symbolizer struct {
+	kernel   *symbolTable
+	mappings map[uint32][]mapping
+	elfs     map[string]*elfSymbols
+}
+
+func newSymbolizer() *symbolizer {
+	s := &symbolizer{
+		mappings: make(map[uint32][]mapping),
+		elfs:     make(map[string]*elfSymbols),
+	}
+	// Without kallsyms kernel frames are shown as addresses
+	s.kernel, _ = loadKernelSymbols()
+	return s
+}
+
+// reset forgets the process mappings, as PIDs get reused between runs.
+func (s *symbolizer) reset() {
+	s.mappings = make(map[uint32][]mapping)
+}
+
+// snapshot records the mappings of a process, once.
+func (s *symbolizer) snapshot(pid uint32) {
+	if _, ok := s.mappings[pid]; ok {
+		return
+	}
+	maps, err := readMappings(pid)
+	if err != nil {
+		return
+	}
+	s.mappings[pid] = maps
+}
+
+func (s *symbolizer) kernelFrame(addr uint64) string {
+	if s.kernel != nil {
+		if name := s.kernel.lookup(addr); name != "" {
+			return name
+		}
+	}
+	return fmt.Sprintf("0x%x", addr)
+}
+
+func (s *symbolizer) userFrame(pid uint32, addr uint64) string {
+	for _, m := range s.mappings[pid] {
+		if addr < m.start || addr >= m.end {
+			continue
+		}
+		fileOffset := addr - m.start + m.offset
+		e, ok := s.elfs[m.path]
+		if !ok {
+			e, _ = loadElfSymbols(m.path)
+			s.elfs[m.path] = e
+		}
+		if e != nil {
+			if name := e.lookup(fileOffset); name != "" {
+				return name
+			}
+		}
+		return fmt.Sprintf("%s+0x%x", filepath.Base(m.path), fileOffset)
+	}
+	return unknownFrame
+}
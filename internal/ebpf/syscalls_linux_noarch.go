@@ -0,0 +1,7 @@
+//go:build linux && !amd64 && !arm64
+
+package ebpf
+
+// syscallNames is empty on other architectures: syscalls are reported by
+// number.
+var syscallNames = map[uint32]string{}
@@ -0,0 +1,120 @@
+package executor
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ArtifactOptions sets how the files written to Executor.ArtifactDir are
+// kept.
+type ArtifactOptions struct {
+	// MaxBytes caps each file, counted before compression (0 = no cap)
+	MaxBytes int64
+	// Gzip compresses the files, named *.log.gz
+	Gzip bool
+}
+
+// Artifacts are the files holding the full output of one run.
+type Artifacts struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	Combined string `json:"combined"`
+	// Truncated is set when a file reached the size cap
+	Truncated bool `json:"truncated,omitempty"`
+	// Error is the first failure writing the files; the run is unaffected
+	Error string `json:"error,omitempty"`
+}
+
+// ParseSize parses a byte count with an optional K, M or G suffix (powers of
+// 1024), e.g. 512K or 10M.
+func ParseSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(s, "B")
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		mult = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (expected e.g. 512K, 10M)", value)
+	}
+	return n * mult, nil
+}
+
+// artifactFile is one output file, capped at MaxBytes and optionally
+// compressed. Writes past the cap are dropped after a note saying so.
+type artifactFile struct {
+	path      string
+	f         *os.File
+	gz        *gzip.Writer
+	w         io.Writer
+	limit     int64
+	written   int64
+	truncated bool
+	err       error
+}
+
+func createArtifact(dir, name string, opts ArtifactOptions) (*artifactFile, error) {
+	name += ".log"
+	if opts.Gzip {
+		name += ".gz"
+	}
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	a := &artifactFile{path: path, f: f, w: f, limit: opts.MaxBytes}
+	if opts.Gzip {
+		a.gz = gzip.NewWriter(f)
+		a.w = a.gz
+	}
+	return a, nil
+}
+
+// Write never fails, so that a full disk does not stop the run: the first
+// error is kept for Close.
+func (a *artifactFile) Write(p []byte) (int, error) {
+	if a.truncated || a.err != nil {
+		return len(p), nil
+	}
+	data := p
+	if a.limit > 0 && a.written+int64(len(data)) > a.limit {
+		data = data[:a.limit-a.written]
+		a.truncated = true
+	}
+	n, err := a.w.Write(data)
+	a.written += int64(n)
+	if err != nil {
+		a.err = err
+	} else if a.truncated {
+		_, a.err = fmt.Fprintf(a.w, "\n[corecut: output truncated at %d bytes]\n", a.limit)
+	}
+	return len(p), nil
+}
+
+// Close flushes the file and returns the first error met writing it.
+func (a *artifactFile) Close() error {
+	if a.gz != nil {
+		if err := a.gz.Close(); err != nil && a.err == nil {
+			a.err = err
+		}
+	}
+	if err := a.f.Close(); err != nil && a.err == nil {
+		a.err = err
+	}
+	return a.err
+}
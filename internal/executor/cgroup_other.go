@@ -0,0 +1,13 @@
+//go:build !linux
+
+package executor
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+func startInCgroup(cmd *exec.Cmd, dir string) (*os.File, error) {
+	return nil, errors.New("cgroups require Linux")
+}
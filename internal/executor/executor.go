@@ -3,24 +3,50 @@ package executor
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
+
+	"github.com/processgain/internal/cgroup"
 )
 
 type RunResult struct {
-	StartTime   time.Time `json:"start_time"`
-	EndTime     time.Time `json:"end_time"`
-	DurationMs  float64   `json:"duration_ms"`
-	ExitCode    int       `json:"exit_code"`
-	Stdout      string    `json:"stdout_tail"`
-	Stderr      string    `json:"stderr_tail"`
-	Error       string    `json:"error,omitempty"`
-	Throughput  float64   `json:"throughput,omitempty"`
-	PID         int       `json:"pid"`
+	StartTime  time.Time     `json:"start_time"`
+	EndTime    time.Time     `json:"end_time"`
+	DurationMs float64       `json:"duration_ms"`
+	ExitCode   int           `json:"exit_code"`
+	Stdout     string        `json:"stdout_tail"`
+	Stderr     string        `json:"stderr_tail"`
+	Error      string        `json:"error,omitempty"`
+	Throughput float64       `json:"throughput,omitempty"`
+	PID        int           `json:"pid"`
+	Resources  ResourceUsage `json:"resources"`
+	// Counters are recorded by the collectors (perf events, ...), by name
+	Counters map[string]float64 `json:"counters,omitempty"`
+	// Cgroup is the usage of the run's cgroup, when it ran in one
+	Cgroup *cgroup.Stats `json:"cgroup,omitempty"`
+	// Strays are the processes still running once bash had exited; they
+	// were terminated
+	Strays []Process `json:"stray_processes,omitempty"`
+	// Artifacts are the files holding the run's full output, when kept
+	Artifacts *Artifacts `json:"artifacts,omitempty"`
+	// Output is the whole stdout, kept with Executor.KeepOutput
+	Output []byte `json:"-"`
+	// Hooks are the before_run and after_run hooks of the run, when set
+	Hooks []HookResult `json:"hooks,omitempty"`
+	// Phases are the phases the script marked, in the order it entered them
+	Phases []Phase `json:"phases,omitempty"`
+	// CustomMetrics are the metrics the script reported itself
+	CustomMetrics []CustomMetric `json:"custom_metrics,omitempty"`
+	// MetricsError lists the metrics that could not be read, one per line;
+	// the others and the run still count
+	MetricsError string `json:"metrics_error,omitempty"`
 }
 
 type Executor struct {
@@ -28,6 +54,24 @@ type Executor struct {
 	CooldownMs int
 	EnvFile    string
 	Env        []string
+	// Sched is inherited by every run's process tree
+	Sched Scheduling
+	// Context stops the running scenario when cancelled (Ctrl-C), like a
+	// timeout does
+	Context context.Context
+	// KillGrace is how long a stopped run's processes get between SIGTERM
+	// and SIGKILL
+	KillGrace time.Duration
+	// ArtifactDir, when set, receives the full output of the next runs:
+	// stdout, stderr and a combined log with timestamps
+	ArtifactDir string
+	Artifacts   ArtifactOptions
+	// KeepOutput keeps the whole stdout of each run in RunResult.Output
+	KeepOutput bool
+	// OnStart, if set, is called with the PID of each run's process once it
+	// exists but before the script starts, so that counters attached to it
+	// see every child. The run's clock starts when it returns.
+	OnStart func(pid int)
 }
 
 func New(timeoutSec, cooldownMs int, envFile string) *Executor {
@@ -36,6 +80,7 @@ func New(timeoutSec, cooldownMs int, envFile string) *Executor {
 		CooldownMs: cooldownMs,
 		EnvFile:    envFile,
 		Env:        os.Environ(),
+		KillGrace:  DefaultKillGrace,
 	}
 
 	if envFile != "" {
@@ -45,6 +90,19 @@ func New(timeoutSec, cooldownMs int, envFile string) *Executor {
 	return e
 }
 
+// AddEnv appends variables to the scenario environment, in key order so that
+// runs are reproducible.
+func (e *Executor) AddEnv(env map[string]string) {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		e.Env = append(e.Env, k+"="+env[k])
+	}
+}
+
 func (e *Executor) loadEnvFile(path string) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -63,35 +121,134 @@ func (e *Executor) loadEnvFile(path string) {
 }
 
 func (e *Executor) Run(script string, mode string) (RunResult, error) {
+	return e.RunInCgroup(script, mode, "", false)
+}
+
+// RunInCgroup runs the script like Run, but starts it directly inside the
+// cgroup v2 directory cgroupDir so that its whole process tree belongs to it,
+// and records the group's usage over the run. fresh tells that the group was
+// created for this run, and has had no process before it.
+func (e *Executor) RunInCgroup(script, mode, cgroupDir string, fresh bool) (RunResult, error) {
 	result := RunResult{}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(e.TimeoutSec)*time.Second)
+	parent := e.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, time.Duration(e.TimeoutSec)*time.Second)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "/bin/bash", "-c", script)
 	cmd.Env = e.Env
+	// The run gets its own process group, so that a timeout or Ctrl-C stops
+	// everything the script started, not only bash
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	var stopped time.Time
+	cmd.Cancel = func() error {
+		stopped = time.Now()
+		return signalGroup(cmd.Process.Pid, syscall.SIGTERM)
+	}
+	// bash itself is killed if it outlives the grace period
+	cmd.WaitDelay = e.KillGrace
+
+	var usage *cgroup.Usage
+	if cgroupDir != "" {
+		dir, err := startInCgroup(cmd, cgroupDir)
+		if err != nil {
+			result.Error = err.Error()
+			return result, err
+		}
+		defer dir.Close()
+		usage = cgroup.StartUsage(cgroupDir, fresh)
+	}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	// The script may write its metrics to this file
+	metricsFile, err := os.CreateTemp("", "corecut-metrics-*.json")
+	if err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+	metricsFile.Close()
+	defer os.Remove(metricsFile.Name())
+	cmd.Env = append(cmd.Env[:len(cmd.Env):len(cmd.Env)], MetricsFileEnv+"="+metricsFile.Name())
+
+	out, err := newOutput(e.ArtifactDir, e.Artifacts)
+	if err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
 
 	result.StartTime = time.Now()
 
-	if err := cmd.Start(); err != nil {
+	err = out.attach(cmd, result.StartTime)
+	var hold, gate *os.File
+	if err == nil && e.OnStart != nil {
+		hold, gate, err = holdScript(cmd)
+	}
+	if err == nil {
+		err = startScheduled(cmd, e.Sched)
+	}
+	out.closeWriters()
+	if hold != nil {
+		hold.Close()
+	}
+	if err != nil {
+		if gate != nil {
+			gate.Close()
+		}
+		out.finish(0)
+		if usage != nil {
+			usage.Stop()
+		}
 		result.Error = err.Error()
 		return result, err
 	}
 
 	result.PID = cmd.Process.Pid
+	rss := sampleRSS(result.PID, cgroupDir)
+	if e.OnStart != nil {
+		e.OnStart(result.PID)
+		result.StartTime = time.Now()
+		out.restart(result.StartTime)
+		gate.Write([]byte("\n"))
+		gate.Close()
+	}
 
-	err := cmd.Wait()
+	err = cmd.Wait()
 	result.EndTime = time.Now()
 	result.DurationMs = float64(result.EndTime.Sub(result.StartTime).Microseconds()) / 1000.0
 
+	// Whatever is still running after bash has exited is a stray: stop it
+	// before it skews the following runs
+	grace := e.KillGrace
+	if !stopped.IsZero() {
+		// A stopped run's group got SIGTERM then: its grace period started
+		grace = max(grace-time.Since(stopped), 0)
+	}
+	result.Strays = terminate(cmd.Process.Pid, cgroupDir, grace)
+
 	result.ExitCode = cmd.ProcessState.ExitCode()
-	result.Stdout = tailString(stdout.String(), 1000)
-	result.Stderr = tailString(stderr.String(), 500)
+	result.Resources = resourceUsage(cmd.ProcessState)
+	result.Resources.MaxRSSKB = rss.stop()
+	if usage != nil {
+		stats := usage.Stop()
+		result.Cgroup = &stats
+	}
+	// A process that escaped both the group and the cgroup may still hold
+	// the pipes: its output is cut
+	result.Artifacts = out.finish(time.Second)
+	result.Phases = out.phases.finish(result.EndTime.Sub(result.StartTime))
+	output := out.stdout.String()
+	if e.KeepOutput {
+		result.Output = out.stdout.Bytes()
+	}
+	result.Stdout = tailString(output, 1000)
+	result.Stderr = tailString(out.stderr.String(), 500)
 
+	if parent.Err() != nil {
+		result.Error = "interrupted"
+		return result, errors.New("interrupted")
+	}
 	if ctx.Err() == context.DeadlineExceeded {
 		result.Error = "timeout"
 		return result, fmt.Errorf("timeout after %ds", e.TimeoutSec)
@@ -101,19 +258,35 @@ func (e *Executor) Run(script string, mode string) (RunResult, error) {
 		result.Error = err.Error()
 	}
 
-	// Parse throughput if mode=throughput
-	if mode == "throughput" {
-		result.Throughput = parseThroughput(stdout.String())
+	if result.CustomMetrics, err = parseMetrics(metricsFile.Name(), output, out.stderr.String()); err != nil {
+		result.MetricsError = err.Error()
 	}
 
-	// Cooldown
-	if e.CooldownMs > 0 {
-		time.Sleep(time.Duration(e.CooldownMs) * time.Millisecond)
+	// Parse throughput if mode=throughput
+	if mode == "throughput" {
+		result.Throughput = parseThroughput(output)
 	}
 
 	return result, nil
 }
 
+// holdScript makes bash wait on a pipe before it runs the script, exec'ing
+// itself so that the PID stays the same. It returns both ends of the pipe:
+// hold is closed once the command has started, and writing to or closing
+// release lets the script go.
+func holdScript(cmd *exec.Cmd) (hold, release *os.File, err error) {
+	hold, release, err = os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	fd := 3 + len(cmd.ExtraFiles)
+	cmd.ExtraFiles = append(cmd.ExtraFiles, hold)
+	script := cmd.Args[len(cmd.Args)-1]
+	gate := fmt.Sprintf(`read -r -u %d _; exec %d<&-; exec /bin/bash -c "$1"`, fd, fd)
+	cmd.Args = []string{cmd.Args[0], "-c", gate, "/bin/bash", script}
+	return hold, release, nil
+}
+
 func tailString(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
@@ -121,6 +294,22 @@ func tailString(s string, maxLen int) string {
 	return "..." + s[len(s)-maxLen:]
 }
 
+// ScriptOutput returns stdout without the lines meant for CoreCut: the
+// throughput line and the phase and metric markers, which change from run
+// to run even when the script computes the same thing.
+func ScriptOutput(stdout []byte) []byte {
+	var out []byte
+	for _, line := range bytes.SplitAfter(stdout, []byte("\n")) {
+		trimmed := bytes.TrimSpace(line)
+		if bytes.HasPrefix(trimmed, []byte(PhaseMarker)) || bytes.HasPrefix(trimmed, []byte(MetricMarker)) ||
+			bytes.HasPrefix(bytes.ToUpper(trimmed), []byte("THROUGHPUT:")) {
+			continue
+		}
+		out = append(out, line...)
+	}
+	return out
+}
+
 func parseThroughput(output string) float64 {
 	lines := strings.Split(strings.TrimSpace(output), "\n")
 	if len(lines) == 0 {
@@ -139,9 +328,12 @@ func parseThroughput(output string) float64 {
 	}
 
 	// Remove units like "ops/sec", "req/s", etc.
-	lastLine = strings.Fields(lastLine)[0]
+	fields := strings.Fields(lastLine)
+	if len(fields) == 0 {
+		return 0
+	}
 
-	val, err := strconv.ParseFloat(lastLine, 64)
+	val, err := strconv.ParseFloat(fields[0], 64)
 	if err != nil {
 		return 0
 	}
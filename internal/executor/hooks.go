@@ -0,0 +1,112 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// Names of the hooks.
+const (
+	HookSetup     = "setup"
+	HookTeardown  = "teardown"
+	HookBeforeRun = "before_run"
+	HookAfterRun  = "after_run"
+)
+
+// Hooks are commands run around a scenario, outside the timed window: setup
+// and teardown once, before_run and after_run around each of its runs.
+type Hooks struct {
+	Setup     string `json:"setup,omitempty"`
+	Teardown  string `json:"teardown,omitempty"`
+	BeforeRun string `json:"before_run,omitempty"`
+	AfterRun  string `json:"after_run,omitempty"`
+}
+
+// IsZero reports whether no hook is set.
+func (h Hooks) IsZero() bool {
+	return h == Hooks{}
+}
+
+// Merge returns h with the hooks set in o replacing its own.
+func (h Hooks) Merge(o Hooks) Hooks {
+	for _, f := range []struct{ dst, src *string }{
+		{&h.Setup, &o.Setup}, {&h.Teardown, &o.Teardown},
+		{&h.BeforeRun, &o.BeforeRun}, {&h.AfterRun, &o.AfterRun},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	return h
+}
+
+// HookResult is the outcome of one hook command.
+type HookResult struct {
+	Hook       string  `json:"hook"`
+	DurationMs float64 `json:"duration_ms"`
+	ExitCode   int     `json:"exit_code"`
+	Error      string  `json:"error,omitempty"`
+	// Output is the tail of a failed hook's stdout and stderr
+	Output string `json:"output,omitempty"`
+}
+
+// RunHook runs a hook command with the scenario environment plus env. It
+// is bounded by the run timeout but not stopped by Ctrl-C, so that
+// after_run and teardown still clean up. Processes it leaves in the
+// background (a server started by setup) are left running: its output goes
+// to a file rather than a pipe they would hold.
+func (e *Executor) RunHook(hook, command string, env ...string) HookResult {
+	result := HookResult{Hook: hook}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(e.TimeoutSec)*time.Second)
+	defer cancel()
+
+	out, err := os.CreateTemp("", "corecut-hook-*")
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	cmd := exec.CommandContext(ctx, "/bin/bash", "-c", command)
+	cmd.Env = append(append([]string{}, e.Env...), env...)
+	cmd.Env = append(cmd.Env, "CORECUT_HOOK="+hook)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return signalGroup(cmd.Process.Pid, syscall.SIGTERM)
+	}
+	cmd.WaitDelay = e.KillGrace
+
+	start := time.Now()
+	err = cmd.Run()
+	result.DurationMs = float64(time.Since(start).Microseconds()) / 1000.0
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		result.Error = fmt.Sprintf("timeout after %ds", e.TimeoutSec)
+	case err != nil:
+		result.Error = err.Error()
+	}
+	if result.Error != "" {
+		output, _ := os.ReadFile(out.Name())
+		result.Output = tailString(string(output), 500)
+	}
+	return result
+}
+
+// Cooldown pauses between runs, once the run's hooks are done.
+func (e *Executor) Cooldown() {
+	if e.CooldownMs > 0 {
+		time.Sleep(time.Duration(e.CooldownMs) * time.Millisecond)
+	}
+}
@@ -0,0 +1,120 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rssInterval is the sampling period of a run's peak RSS.
+const rssInterval = 10 * time.Millisecond
+
+// rssSampler follows the peak RSS of a run's processes from their VmHWM. A
+// process starts a fresh high-water mark at exec, unlike ru_maxrss, which
+// the script inherits from CoreCut itself. Processes living less than the
+// interval may be missed, so the peak is a lower bound.
+type rssSampler struct {
+	root      int
+	cgroupDir string
+	peakKB    int64
+	done      chan struct{}
+	wait      chan struct{}
+}
+
+// sampleRSS starts sampling the process tree rooted at pid, and the
+// processes of cgroupDir when set.
+func sampleRSS(pid int, cgroupDir string) *rssSampler {
+	s := &rssSampler{root: pid, cgroupDir: cgroupDir, done: make(chan struct{}), wait: make(chan struct{})}
+	s.sample()
+	go func() {
+		defer close(s.wait)
+		ticker := time.NewTicker(rssInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				s.sample()
+			}
+		}
+	}()
+	return s
+}
+
+// stop ends sampling and returns the highest VmHWM seen, in kilobytes, or
+// 0 when no process could be read.
+func (s *rssSampler) stop() int64 {
+	close(s.done)
+	<-s.wait
+	return s.peakKB
+}
+
+func (s *rssSampler) sample() {
+	pids := descendants(s.root)
+	if s.cgroupDir != "" {
+		if data, err := os.ReadFile(filepath.Join(s.cgroupDir, "cgroup.procs")); err == nil {
+			for _, field := range strings.Fields(string(data)) {
+				if pid, err := strconv.Atoi(field); err == nil {
+					pids = append(pids, pid)
+				}
+			}
+		}
+	}
+	for _, pid := range pids {
+		if kb := readHWM(pid); kb > s.peakKB {
+			s.peakKB = kb
+		}
+	}
+}
+
+// descendants returns root and the processes below it, through the
+// children lists of their threads. Without them (a kernel built without
+// CONFIG_PROC_CHILDREN) only root is returned.
+func descendants(root int) []int {
+	pids := []int{root}
+	for i := 0; i < len(pids); i++ {
+		dir := "/proc/" + strconv.Itoa(pids[i]) + "/task"
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			data, err := os.ReadFile(filepath.Join(dir, e.Name(), "children"))
+			if err != nil {
+				continue
+			}
+			for _, field := range strings.Fields(string(data)) {
+				if child, err := strconv.Atoi(field); err == nil {
+					pids = append(pids, child)
+				}
+			}
+		}
+	}
+	return pids
+}
+
+// readHWM returns the VmHWM of a process in kilobytes, 0 for a process that
+// exited or has no memory of its own (a kernel thread or a zombie).
+func readHWM(pid int) int64 {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/status")
+	if err != nil {
+		return 0
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// VmHWM:	    3456 kB
+		if value, ok := strings.CutPrefix(scanner.Text(), "VmHWM:"); ok {
+			fields := strings.Fields(value)
+			if len(fields) > 0 {
+				kb, _ := strconv.ParseInt(fields[0], 10, 64)
+				return kb
+			}
+		}
+	}
+	return 0
+}
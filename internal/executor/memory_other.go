@@ -0,0 +1,14 @@
+//go:build !linux
+
+package executor
+
+// rssSampler needs /proc: outside Linux the peak RSS is not recorded.
+type rssSampler struct{}
+
+func sampleRSS(pid int, cgroupDir string) *rssSampler {
+	return &rssSampler{}
+}
+
+func (s *rssSampler) stop() int64 {
+	return 0
+}
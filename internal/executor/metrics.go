@@ -0,0 +1,183 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MetricMarker starts the lines a script prints, on stdout or stderr, to
+// report metrics of its own:
+//
+//	CORECUT_METRIC rows_per_s=125000 unit=rows/s better=higher
+const MetricMarker = "CORECUT_METRIC"
+
+// MetricsFileEnv holds the path of a file the script can write its metrics
+// to instead, as a JSON object of plain values or of value, unit and better:
+//
+//	{"rows_per_s": 125000, "p99": {"value": 12.5, "unit": "ms", "better": "lower"}}
+const MetricsFileEnv = "CORECUT_METRICS_FILE"
+
+// Which way a custom metric improves.
+const (
+	BetterLower  = "lower"
+	BetterHigher = "higher"
+)
+
+// CustomMetric is a value reported by the script itself. A metric reported
+// twice in a run keeps its last value.
+type CustomMetric struct {
+	Name   string  `json:"name"`
+	Value  float64 `json:"value"`
+	Unit   string  `json:"unit,omitempty"`
+	Better string  `json:"better"`
+}
+
+// CustomMetric returns the run's custom metric of the given name, if the
+// script reported it.
+func (r RunResult) CustomMetric(name string) (CustomMetric, bool) {
+	for _, m := range r.CustomMetrics {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return CustomMetric{}, false
+}
+
+// parseMetrics collects the metrics of a run: the marker lines of its
+// output, then the metrics file, if the script wrote it. An invalid line or
+// entry is skipped and reported in the error, alongside the valid metrics.
+func parseMetrics(metricsFile string, outputs ...string) ([]CustomMetric, error) {
+	var metrics []CustomMetric
+	var errs []error
+	for _, output := range outputs {
+		for _, line := range strings.Split(output, "\n") {
+			if !strings.HasPrefix(line, MetricMarker) {
+				continue
+			}
+			m, err := parseMetricLine(line)
+			if err != nil {
+				errs = append(errs, err)
+			} else if m != nil {
+				metrics = addMetric(metrics, *m)
+			}
+		}
+	}
+
+	data, err := os.ReadFile(metricsFile)
+	if err != nil || len(strings.TrimSpace(string(data))) == 0 {
+		return metrics, errors.Join(errs...)
+	}
+	fileMetrics, err := parseMetricsJSON(data)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for _, m := range fileMetrics {
+		metrics = addMetric(metrics, m)
+	}
+	return metrics, errors.Join(errs...)
+}
+
+// parseMetricLine parses "CORECUT_METRIC name=value [unit=U] [better=B]".
+// It returns nil for a line that only starts like a marker.
+func parseMetricLine(line string) (*CustomMetric, error) {
+	fields := strings.Fields(line)
+	if fields[0] != MetricMarker {
+		return nil, nil
+	}
+	m := CustomMetric{}
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid %s line %q: expected key=value", MetricMarker, line)
+		}
+		switch {
+		case key == "unit":
+			m.Unit = value
+		case key == "better":
+			m.Better = value
+		case m.Name == "":
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s line %q: %s is not a number", MetricMarker, line, value)
+			}
+			m.Name, m.Value = key, v
+		default:
+			return nil, fmt.Errorf("invalid %s line %q: unknown key %s", MetricMarker, line, key)
+		}
+	}
+	if m.Name == "" {
+		return nil, fmt.Errorf("invalid %s line %q: no name=value", MetricMarker, line)
+	}
+	if err := validateMetric(&m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// parseMetricsJSON parses the metrics file, in name order. Invalid entries
+// are left out, and reported in the error with the valid metrics.
+func parseMetricsJSON(data []byte) ([]CustomMetric, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid metrics file: %w", err)
+	}
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	metrics := make([]CustomMetric, 0, len(names))
+	var errs []error
+	for _, name := range names {
+		m := CustomMetric{Name: name}
+		if err := json.Unmarshal(raw[name], &m.Value); err != nil {
+			var full struct {
+				Value  *float64 `json:"value"`
+				Unit   string   `json:"unit"`
+				Better string   `json:"better"`
+			}
+			if err := json.Unmarshal(raw[name], &full); err != nil || full.Value == nil {
+				errs = append(errs, fmt.Errorf("invalid metrics file entry %s: expected a number or {\"value\": ...}", name))
+				continue
+			}
+			m.Value, m.Unit, m.Better = *full.Value, full.Unit, full.Better
+		}
+		if err := validateMetric(&m); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, errors.Join(errs...)
+}
+
+func validateMetric(m *CustomMetric) error {
+	if math.IsNaN(m.Value) || math.IsInf(m.Value, 0) {
+		return fmt.Errorf("metric %s: %v is not a finite number", m.Name, m.Value)
+	}
+	switch m.Better {
+	case "":
+		m.Better = BetterLower
+	case BetterLower, BetterHigher:
+	default:
+		return fmt.Errorf("metric %s: better must be %s or %s, not %q", m.Name, BetterLower, BetterHigher, m.Better)
+	}
+	return nil
+}
+
+func addMetric(metrics []CustomMetric, m CustomMetric) []CustomMetric {
+	for i := range metrics {
+		if metrics[i].Name == m.Name {
+			metrics[i] = m
+			return metrics
+		}
+	}
+	return append(metrics, m)
+}
@@ -0,0 +1,196 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// output captures the stdout and stderr of a run. Both are read from pipes
+// and kept whole in memory; with an artifact directory they are also written
+// to files, along with a combined log whose lines are timestamped from the
+// start of the run. Phase markers are picked out of both streams and of the
+// phase pipe.
+type output struct {
+	stdout, stderr bytes.Buffer
+	readers        []*os.File
+	writers        []*os.File
+	wg             sync.WaitGroup
+
+	// files are the stdout, stderr and combined artifacts, when kept
+	files    []*artifactFile
+	start    time.Time
+	mu       sync.Mutex // guards start and the combined log
+	last     string
+	midLine  bool
+	combined *artifactFile
+
+	phases phaseTracker
+}
+
+// newOutput prepares the capture of a run, creating dir and the artifact
+// files in it when dir is set.
+func newOutput(dir string, opts ArtifactOptions) (*output, error) {
+	o := &output{}
+	if dir == "" {
+		return o, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create artifact dir: %w", err)
+	}
+	for _, name := range []string{"stdout", "stderr", "combined"} {
+		f, err := createArtifact(dir, name, opts)
+		if err != nil {
+			o.closeFiles()
+			return nil, fmt.Errorf("failed to create artifact: %w", err)
+		}
+		o.files = append(o.files, f)
+	}
+	o.combined = o.files[2]
+	return o, nil
+}
+
+// attach connects the command's stdout, stderr and phase descriptor to the
+// capture. The pipes are handed to the command as files, so Wait does not
+// wait for a stray process holding them open. On error the capture must
+// still be finished.
+func (o *output) attach(cmd *exec.Cmd, start time.Time) error {
+	o.start = start
+	for i, stream := range []string{"stdout", "stderr", "phases"} {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return err
+		}
+		o.readers = append(o.readers, r)
+		o.writers = append(o.writers, w)
+		var mem *bytes.Buffer
+		var file *artifactFile
+		switch i {
+		case 0:
+			mem = &o.stdout
+		case 1:
+			mem = &o.stderr
+		}
+		if o.files != nil && mem != nil {
+			file = o.files[i]
+		}
+		o.wg.Add(1)
+		go o.copy(r, mem, file, stream)
+	}
+	cmd.Stdout, cmd.Stderr = o.writers[0], o.writers[1]
+	cmd.ExtraFiles = []*os.File{o.writers[2]}
+	cmd.Env = append(cmd.Env[:len(cmd.Env):len(cmd.Env)], fmt.Sprintf("%s=%d", PhaseFDEnv, phaseFD))
+	return nil
+}
+
+// closeWriters closes our ends of the pipes once the command has started
+// with its own copies.
+func (o *output) closeWriters() {
+	for _, w := range o.writers {
+		w.Close()
+	}
+}
+
+// finish waits for the output to be read up to the end, at most timeout:
+// the pipes are then closed under any process still holding them. It
+// returns the artifacts written, if any.
+func (o *output) finish(timeout time.Duration) *Artifacts {
+	done := make(chan struct{})
+	go func() {
+		o.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		for _, r := range o.readers {
+			r.Close()
+		}
+		<-done
+	}
+	for _, r := range o.readers {
+		r.Close()
+	}
+
+	if o.files == nil {
+		return nil
+	}
+	a := &Artifacts{Stdout: o.files[0].path, Stderr: o.files[1].path, Combined: o.files[2].path}
+	for _, f := range o.files {
+		a.Truncated = a.Truncated || f.truncated
+	}
+	if err := o.closeFiles(); err != nil {
+		a.Error = err.Error()
+	}
+	return a
+}
+
+// restart moves the start of the run, once the script is let go.
+func (o *output) restart(start time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.start = start
+}
+
+func (o *output) elapsed() time.Duration {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return time.Since(o.start)
+}
+
+func (o *output) closeFiles() error {
+	var first error
+	for _, f := range o.files {
+		if err := f.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (o *output) copy(r *os.File, mem *bytes.Buffer, file *artifactFile, stream string) {
+	defer o.wg.Done()
+	br := bufio.NewReader(r)
+	lineStart := true
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if len(chunk) > 0 {
+			if lineStart {
+				o.phases.mark(chunk, o.elapsed())
+			}
+			lineStart = chunk[len(chunk)-1] == '\n'
+			if mem != nil {
+				mem.Write(chunk)
+			}
+			if file != nil {
+				file.Write(chunk)
+				o.log(stream, chunk)
+			}
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return
+		}
+	}
+}
+
+// log appends a chunk to the combined log, starting each line with the time
+// since the start of the run and its stream.
+func (o *output) log(stream string, chunk []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.midLine && o.last != stream {
+		// The other stream's partial line is ended rather than interleaved
+		o.combined.Write([]byte("\n"))
+		o.midLine = false
+	}
+	if !o.midLine {
+		fmt.Fprintf(o.combined, "%10.3fs %s | ", time.Since(o.start).Seconds(), stream)
+	}
+	o.combined.Write(chunk)
+	o.last = stream
+	o.midLine = chunk[len(chunk)-1] != '\n'
+}
@@ -0,0 +1,110 @@
+package executor
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PhaseMarker starts the lines a script prints to mark its phases, on
+// stdout, stderr or the phase file descriptor:
+//
+//	CORECUT_PHASE start load
+//	CORECUT_PHASE end load
+const PhaseMarker = "CORECUT_PHASE"
+
+// PhaseFDEnv holds the number of the file descriptor a script can write its
+// markers to, keeping them out of its output.
+const PhaseFDEnv = "CORECUT_PHASE_FD"
+
+// phaseFD is the phase pipe in the script, the first after stdin, stdout
+// and stderr.
+const phaseFD = 3
+
+// Phase is the time a run spent in one of its named phases. A phase entered
+// several times adds up.
+type Phase struct {
+	Name string `json:"name"`
+	// StartMs is when the phase was first entered, since the start of the run
+	StartMs    float64 `json:"start_ms"`
+	DurationMs float64 `json:"duration_ms"`
+	// Unterminated is set when the run ended inside the phase
+	Unterminated bool `json:"unterminated,omitempty"`
+}
+
+// Phase returns the run's phase of the given name, if it marked one.
+func (r RunResult) Phase(name string) (Phase, bool) {
+	for _, p := range r.Phases {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Phase{}, false
+}
+
+// phaseTracker timestamps the markers of a run as they are read.
+type phaseTracker struct {
+	mu     sync.Mutex
+	phases []Phase
+	index  map[string]int
+	open   map[string]time.Duration
+}
+
+// mark records the marker in line, if it is one, read at elapsed since the
+// start of the run. Starting an open phase or ending a closed one is
+// ignored.
+func (t *phaseTracker) mark(line []byte, elapsed time.Duration) {
+	if !bytes.HasPrefix(line, []byte(PhaseMarker)) {
+		return
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) < 3 || fields[0] != PhaseMarker {
+		return
+	}
+	action, name := fields[1], strings.Join(fields[2:], " ")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.open == nil {
+		t.open = make(map[string]time.Duration)
+		t.index = make(map[string]int)
+	}
+	start, open := t.open[name]
+	switch {
+	case action == "start" && !open:
+		t.open[name] = elapsed
+		t.phase(name, elapsed)
+	case action == "end" && open:
+		delete(t.open, name)
+		t.phase(name, start).DurationMs += milliseconds(elapsed - start)
+	}
+}
+
+func (t *phaseTracker) phase(name string, start time.Duration) *Phase {
+	i, ok := t.index[name]
+	if !ok {
+		i = len(t.phases)
+		t.index[name] = i
+		t.phases = append(t.phases, Phase{Name: name, StartMs: milliseconds(start)})
+	}
+	return &t.phases[i]
+}
+
+// finish ends the phases still open at end, the duration of the run, and
+// returns the phases in the order they were first entered.
+func (t *phaseTracker) finish(end time.Duration) []Phase {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for name, start := range t.open {
+		p := t.phase(name, start)
+		p.DurationMs += milliseconds(max(end-start, 0))
+		p.Unterminated = true
+	}
+	t.open = nil
+	return t.phases
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000.0
+}
@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultKillGrace is how long a stopped run gets to exit after SIGTERM
+// before its processes are killed.
+const DefaultKillGrace = 5 * time.Second
+
+// Process is a process left running by a scenario after its run.
+type Process struct {
+	PID     int    `json:"pid"`
+	Command string `json:"command"`
+}
+
+func (p Process) String() string {
+	return fmt.Sprintf("%d (%s)", p.PID, p.Command)
+}
+
+// FormatProcesses lists processes for the console, "pid (comm), ...".
+func FormatProcesses(procs []Process) string {
+	parts := make([]string, len(procs))
+	for i, p := range procs {
+		parts[i] = p.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// signalGroup sends sig to every process of the process group pgid.
+func signalGroup(pgid int, sig syscall.Signal) error {
+	err := syscall.Kill(-pgid, sig)
+	if err == syscall.ESRCH {
+		return nil
+	}
+	return err
+}
+
+// terminate stops the processes left by a run: the members of its process
+// group and of its cgroup, if any. They get SIGTERM, then SIGKILL once grace
+// has elapsed. It returns the processes found.
+func terminate(pgid int, cgroupDir string, grace time.Duration) []Process {
+	strays := findStrays(pgid, cgroupDir)
+	if len(strays) == 0 {
+		return nil
+	}
+	signalAll(pgid, strays, syscall.SIGTERM)
+	if waitGone(pgid, cgroupDir, grace) {
+		return strays
+	}
+	signalAll(pgid, findStrays(pgid, cgroupDir), syscall.SIGKILL)
+	// SIGKILL is delivered asynchronously; the cgroup cannot be removed
+	// before its processes have exited
+	waitGone(pgid, cgroupDir, time.Second)
+	return strays
+}
+
+// waitGone polls until no process is left or timeout has elapsed, and
+// reports whether they are all gone.
+func waitGone(pgid int, cgroupDir string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if len(findStrays(pgid, cgroupDir)) == 0 {
+			return true
+		}
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func signalAll(pgid int, procs []Process, sig syscall.Signal) {
+	signalGroup(pgid, sig)
+	// Processes that left the group (setsid) but not the cgroup
+	for _, p := range procs {
+		syscall.Kill(p.PID, sig)
+	}
+}
@@ -0,0 +1,73 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// findStrays returns the live processes of the process group pgid and of
+// the cgroup directory cgroupDir (when set). Zombies are left out: they no
+// longer run and go away once reaped.
+func findStrays(pgid int, cgroupDir string) []Process {
+	var strays []Process
+	seen := make(map[int]bool)
+	add := func(pid int) {
+		if seen[pid] {
+			return
+		}
+		seen[pid] = true
+		if comm, state, group, ok := readStat(pid); ok && state != "Z" && (group == pgid || cgroupDir != "") {
+			strays = append(strays, Process{PID: pid, Command: comm})
+		}
+	}
+
+	if cgroupDir != "" {
+		if data, err := os.ReadFile(filepath.Join(cgroupDir, "cgroup.procs")); err == nil {
+			for _, field := range strings.Fields(string(data)) {
+				if pid, err := strconv.Atoi(field); err == nil {
+					add(pid)
+				}
+			}
+		}
+	}
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return strays
+	}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || seen[pid] {
+			continue
+		}
+		if _, _, group, ok := readStat(pid); ok && group == pgid {
+			add(pid)
+		}
+	}
+	return strays
+}
+
+// readStat returns the command name, state and process group of a process.
+func readStat(pid int) (comm, state string, pgid int, ok bool) {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return "", "", 0, false
+	}
+	// pid (comm) state ppid pgrp ...; the name may contain spaces
+	stat := string(data)
+	open, end := strings.IndexByte(stat, '('), strings.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return "", "", 0, false
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 3 {
+		return "", "", 0, false
+	}
+	pgid, err = strconv.Atoi(fields[2])
+	if err != nil {
+		return "", "", 0, false
+	}
+	return stat[open+1 : end], fields[0], pgid, true
+}
@@ -0,0 +1,21 @@
+package executor
+
+// ResourceUsage is the rusage of a scenario's process tree, as reported by
+// wait4 for the shell and every descendant it reaped.
+type ResourceUsage struct {
+	UserCPUMs float64 `json:"user_cpu_ms"`
+	SysCPUMs  float64 `json:"sys_cpu_ms"`
+	// MaxRSSKB is the highest VmHWM sampled among the processes of the run,
+	// 0 when not recorded. ru_maxrss would include CoreCut's own peak, which
+	// the script inherits when it is started.
+	MaxRSSKB         int64 `json:"max_rss_kb,omitempty"`
+	MinorFaults      int64 `json:"minor_faults"`
+	MajorFaults      int64 `json:"major_faults"`
+	VolCtxSwitches   int64 `json:"voluntary_ctx_switches"`
+	InvolCtxSwitches int64 `json:"involuntary_ctx_switches"`
+}
+
+// CPUMs is the total (user + system) CPU time.
+func (u ResourceUsage) CPUMs() float64 {
+	return u.UserCPUMs + u.SysCPUMs
+}
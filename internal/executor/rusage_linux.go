@@ -0,0 +1,25 @@
+package executor
+
+import (
+	"os"
+	"syscall"
+)
+
+func resourceUsage(state *os.ProcessState) ResourceUsage {
+	if state == nil {
+		return ResourceUsage{}
+	}
+	ru, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok || ru == nil {
+		return ResourceUsage{}
+	}
+
+	return ResourceUsage{
+		UserCPUMs:        float64(state.UserTime().Microseconds()) / 1000.0,
+		SysCPUMs:         float64(state.SystemTime().Microseconds()) / 1000.0,
+		MinorFaults:      ru.Minflt,
+		MajorFaults:      ru.Majflt,
+		VolCtxSwitches:   ru.Nvcsw,
+		InvolCtxSwitches: ru.Nivcsw,
+	}
+}
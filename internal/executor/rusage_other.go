@@ -0,0 +1,15 @@
+//go:build !linux
+
+package executor
+
+import "os"
+
+func resourceUsage(state *os.ProcessState) ResourceUsage {
+	if state == nil {
+		return ResourceUsage{}
+	}
+	return ResourceUsage{
+		UserCPUMs: float64(state.UserTime().Microseconds()) / 1000.0,
+		SysCPUMs:  float64(state.SystemTime().Microseconds()) / 1000.0,
+	}
+}
@@ -0,0 +1,112 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// I/O scheduling classes of ionice.
+const (
+	IOClassNone       = 0
+	IOClassRealtime   = 1
+	IOClassBestEffort = 2
+	IOClassIdle       = 3
+)
+
+var ioClassNames = map[string]int{
+	"realtime":    IOClassRealtime,
+	"best-effort": IOClassBestEffort,
+	"idle":        IOClassIdle,
+}
+
+// Scheduling is how the runs are scheduled: the CPUs they may run on, their
+// nice value and I/O priority. The zero value leaves everything as
+// inherited from CoreCut.
+type Scheduling struct {
+	CPUs []int
+	Nice int
+	// IOClass is one of the IOClass constants, with a priority level of
+	// 0 (highest) to 7 for the realtime and best-effort classes
+	IOClass int
+	IOLevel int
+}
+
+// IsZero reports whether the runs keep the inherited scheduling.
+func (s Scheduling) IsZero() bool {
+	return len(s.CPUs) == 0 && s.Nice == 0 && s.IOClass == IOClassNone
+}
+
+// ParseCPUList parses a CPU list such as "0-3,8,10-11", sorted and without
+// duplicates.
+func ParseCPUList(list string) ([]int, error) {
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("invalid CPU list %q", list)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(hi); err != nil || last < first {
+				return nil, fmt.Errorf("invalid CPU list %q", list)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			seen[cpu] = true
+		}
+	}
+	if len(seen) == 0 {
+		return nil, fmt.Errorf("empty CPU list %q", list)
+	}
+	cpus := make([]int, 0, len(seen))
+	for cpu := range seen {
+		cpus = append(cpus, cpu)
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
+// FormatCPUList renders sorted CPUs as a CPU list, with ranges.
+func FormatCPUList(cpus []int) string {
+	var parts []string
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", cpus[i], cpus[j]))
+		} else {
+			parts = append(parts, strconv.Itoa(cpus[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseIONice parses "class[:level]", e.g. "idle" or "best-effort:2".
+func ParseIONice(value string) (class, level int, err error) {
+	name, lvl, hasLevel := strings.Cut(value, ":")
+	class, ok := ioClassNames[name]
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid I/O class %q (expected realtime, best-effort or idle)", name)
+	}
+	if hasLevel {
+		if class == IOClassIdle {
+			return 0, 0, fmt.Errorf("the idle I/O class takes no level")
+		}
+		if level, err = strconv.Atoi(lvl); err != nil || level < 0 || level > 7 {
+			return 0, 0, fmt.Errorf("invalid I/O priority level %q (expected 0-7)", lvl)
+		}
+	} else if class != IOClassIdle {
+		level = 4
+	}
+	return class, level, nil
+}
@@ -0,0 +1,86 @@
+package executor
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+const ioprioWhoProcess = 1
+
+// AllowedCPUs returns the CPUs CoreCut itself may run on.
+func AllowedCPUs() ([]int, error) {
+	var set unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &set); err != nil {
+		return nil, err
+	}
+	var cpus []int
+	for cpu := 0; cpu < len(set)*64; cpu++ {
+		if set.IsSet(cpu) {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// Check applies the settings to a throwaway thread, to report missing
+// privileges (negative nice, realtime I/O) or unknown CPUs before the runs.
+func (s Scheduling) Check() error {
+	if s.IsZero() {
+		return nil
+	}
+	return onSchedThread(s, func() error { return nil })
+}
+
+// startScheduled starts cmd with the scheduling settings, which the child
+// inherits from the thread that forks it.
+func startScheduled(cmd *exec.Cmd, s Scheduling) error {
+	if s.IsZero() {
+		return cmd.Start()
+	}
+	return onSchedThread(s, cmd.Start)
+}
+
+// onSchedThread runs fn on an OS thread with the settings applied. The
+// thread is not unlocked, so it exits with the goroutine rather than go
+// back to the runtime with them (a nice value may not be lowered back).
+func onSchedThread(s Scheduling, fn func() error) error {
+	errc := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		if err := s.apply(); err != nil {
+			errc <- err
+			return
+		}
+		errc <- fn()
+	}()
+	return <-errc
+}
+
+// apply sets the calling thread's affinity, nice value and I/O priority.
+func (s Scheduling) apply() error {
+	if len(s.CPUs) > 0 {
+		var set unix.CPUSet
+		for _, cpu := range s.CPUs {
+			set.Set(cpu)
+		}
+		if err := unix.SchedSetaffinity(0, &set); err != nil {
+			return fmt.Errorf("failed to pin to CPUs %s: %w", FormatCPUList(s.CPUs), err)
+		}
+	}
+	if s.Nice != 0 {
+		// Nice values are per thread on Linux: who 0 is the calling thread
+		if err := unix.Setpriority(unix.PRIO_PROCESS, 0, s.Nice); err != nil {
+			return fmt.Errorf("failed to set nice %d: %w", s.Nice, err)
+		}
+	}
+	if s.IOClass != IOClassNone {
+		prio := s.IOClass<<13 | s.IOLevel
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, 0, uintptr(prio)); errno != 0 {
+			return fmt.Errorf("failed to set I/O priority: %w", errno)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package executor
+
+import (
+	"errors"
+	"os/exec"
+)
+
+var errSchedUnsupported = errors.New("CPU pinning, nice and ionice require Linux")
+
+func AllowedCPUs() ([]int, error) {
+	return nil, errSchedUnsupported
+}
+
+func (s Scheduling) Check() error {
+	if s.IsZero() {
+		return nil
+	}
+	return errSchedUnsupported
+}
+
+func startScheduled(cmd *exec.Cmd, s Scheduling) error {
+	if s.IsZero() {
+		return cmd.Start()
+	}
+	return errSchedUnsupported
+}
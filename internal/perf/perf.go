@@ -0,0 +1,35 @@
+// Package perf counts hardware and software events of a scenario's process
+// tree with perf_event_open. Counters are attached to the run's root process
+// with inheritance, so its children are counted as they exit.
+package perf
+
+import "errors"
+
+// Names of the counters read for each run.
+const (
+	Instructions    = "instructions"
+	Cycles          = "cycles"
+	CacheMisses     = "cache_misses"
+	BranchMisses    = "branch_misses"
+	TaskClock       = "task_clock"
+	ContextSwitches = "context_switches"
+	CPUMigrations   = "cpu_migrations"
+	// IPC is derived from instructions and cycles
+	IPC = "ipc"
+)
+
+// ErrUnsupported is returned where perf_event_open does not exist.
+var ErrUnsupported = errors.New("perf_event_open is only available on Linux")
+
+// Counts holds the counter values of one run. Task clock is in nanoseconds.
+// Counters the kernel multiplexed are scaled to the whole run.
+type Counts map[string]float64
+
+// derive adds the ratios computed from the raw counters.
+func (c Counts) derive() {
+	if c[Cycles] > 0 {
+		if instructions, ok := c[Instructions]; ok {
+			c[IPC] = instructions / c[Cycles]
+		}
+	}
+}
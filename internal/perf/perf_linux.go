@@ -0,0 +1,174 @@
+//go:build linux
+
+package perf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+type event struct {
+	name     string
+	typ      uint32
+	config   uint64
+	hardware bool
+	// group names the events scheduled together on the PMU, under the first
+	// one opened: the ratios derived from them need the same time windows
+	group string
+}
+
+var events = []event{
+	{Instructions, unix.PERF_TYPE_HARDWARE, unix.PERF_COUNT_HW_INSTRUCTIONS, true, IPC},
+	{Cycles, unix.PERF_TYPE_HARDWARE, unix.PERF_COUNT_HW_CPU_CYCLES, true, IPC},
+	{CacheMisses, unix.PERF_TYPE_HARDWARE, unix.PERF_COUNT_HW_CACHE_MISSES, true, ""},
+	{BranchMisses, unix.PERF_TYPE_HARDWARE, unix.PERF_COUNT_HW_BRANCH_MISSES, true, ""},
+	{TaskClock, unix.PERF_TYPE_SOFTWARE, unix.PERF_COUNT_SW_TASK_CLOCK, false, ""},
+	{ContextSwitches, unix.PERF_TYPE_SOFTWARE, unix.PERF_COUNT_SW_CONTEXT_SWITCHES, false, ""},
+	{CPUMigrations, unix.PERF_TYPE_SOFTWARE, unix.PERF_COUNT_SW_CPU_MIGRATIONS, false, ""},
+}
+
+// Counter opens the events of each run in turn.
+type Counter struct {
+	hardware bool
+	userOnly bool
+
+	fds   []int
+	names []string
+}
+
+// New probes which events this machine can count: hardware counters are
+// usually missing in VMs, and perf_event_paranoid may restrict unprivileged
+// users to user-space events.
+func New() (*Counter, error) {
+	c := &Counter{}
+	pid := os.Getpid()
+	var err error
+	for _, userOnly := range []bool{false, true} {
+		var fd int
+		if fd, err = open(eventNamed(TaskClock), pid, userOnly, -1); err == nil {
+			unix.Close(fd)
+			c.userOnly = userOnly
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("perf_event_open: %w", err)
+	}
+	if fd, err := open(eventNamed(Instructions), pid, c.userOnly, -1); err == nil {
+		unix.Close(fd)
+		c.hardware = true
+	}
+	return c, nil
+}
+
+// Hardware reports whether the hardware counters are available; without
+// them only the software counters are read.
+func (c *Counter) Hardware() bool { return c.hardware }
+
+// UserOnly reports whether kernel-space events are excluded.
+func (c *Counter) UserOnly() bool { return c.userOnly }
+
+// Attach starts counting the process tree rooted at pid.
+func (c *Counter) Attach(pid int) error {
+	c.detach()
+	leaders := make(map[string]int)
+	for _, e := range events {
+		if e.hardware && !c.hardware {
+			continue
+		}
+		leader, grouped := leaders[e.group]
+		if !grouped {
+			leader = -1
+		}
+		fd, err := open(e, pid, c.userOnly, leader)
+		if err != nil {
+			// Some PMUs lack a generic event (e.g. cache misses): skip it
+			if e.hardware {
+				continue
+			}
+			c.detach()
+			return fmt.Errorf("perf_event_open %s: %w", e.name, err)
+		}
+		if e.group != "" && !grouped {
+			leaders[e.group] = fd
+		}
+		c.fds = append(c.fds, fd)
+		c.names = append(c.names, e.name)
+	}
+	return nil
+}
+
+// Read returns the counts since Attach and closes the counters. It must be
+// called once the process tree has exited.
+func (c *Counter) Read() (Counts, error) {
+	defer c.detach()
+	if len(c.fds) == 0 {
+		return nil, errors.New("perf counters not attached")
+	}
+
+	counts := make(Counts)
+	// value, time enabled, time running
+	buf := make([]byte, 24)
+	for i, fd := range c.fds {
+		if n, err := unix.Read(fd, buf); err != nil || n != len(buf) {
+			continue
+		}
+		value := binary.NativeEndian.Uint64(buf[0:])
+		enabled := binary.NativeEndian.Uint64(buf[8:])
+		running := binary.NativeEndian.Uint64(buf[16:])
+		if running == 0 {
+			// Never scheduled on the PMU
+			continue
+		}
+		v := float64(value)
+		if running < enabled {
+			v *= float64(enabled) / float64(running)
+		}
+		counts[c.names[i]] = v
+	}
+	counts.derive()
+	return counts, nil
+}
+
+// Close releases the counters of an unfinished run.
+func (c *Counter) Close() {
+	c.detach()
+}
+
+func (c *Counter) detach() {
+	for _, fd := range c.fds {
+		unix.Close(fd)
+	}
+	c.fds, c.names = nil, nil
+}
+
+func eventNamed(name string) event {
+	for _, e := range events {
+		if e.name == name {
+			return e
+		}
+	}
+	panic("perf: unknown event " + name)
+}
+
+// open counts an event for pid and the children it forks afterwards, on
+// any CPU, in the group of leader (-1 for none). Each member of a group is
+// read on its own, with the time windows of the whole group.
+func open(e event, pid int, userOnly bool, leader int) (int, error) {
+	attr := unix.PerfEventAttr{
+		Type:        e.typ,
+		Config:      e.config,
+		Size:        uint32(unsafe.Sizeof(unix.PerfEventAttr{})),
+		Read_format: unix.PERF_FORMAT_TOTAL_TIME_ENABLED | unix.PERF_FORMAT_TOTAL_TIME_RUNNING,
+		Bits:        unix.PerfBitInherit,
+	}
+	if userOnly {
+		attr.Bits |= unix.PerfBitExcludeKernel | unix.PerfBitExcludeHv
+	}
+	return unix.PerfEventOpen(&attr, pid, -1, leader, unix.PERF_FLAG_FD_CLOEXEC)
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package perf
+
+// Counter is unavailable outside Linux.
+type Counter struct{}
+
+func New() (*Counter, error) { return nil, ErrUnsupported }
+
+func (c *Counter) Hardware() bool { return false }
+
+func (c *Counter) UserOnly() bool { return false }
+
+func (c *Counter) Attach(pid int) error { return ErrUnsupported }
+
+func (c *Counter) Read() (Counts, error) { return nil, ErrUnsupported }
+
+func (c *Counter) Close() {}
@@ -0,0 +1,284 @@
+// Package procfs samples the process tree of a scenario from /proc. It needs
+// no privileges, and gives a coarser view of where the time went than the
+// eBPF collector when that one is unavailable.
+package procfs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/processgain/internal/ebpf"
+)
+
+// DefaultInterval is the sampling period of the process tree.
+const DefaultInterval = 25 * time.Millisecond
+
+// taskStats holds the last counters seen for one thread.
+type taskStats struct {
+	runNs, waitNs, timeslices int64
+	voluntary, involuntary    int64
+	// I/O counters are per process, kept on the leader thread
+	readBytes, writeBytes int64
+	readChars, writeChars int64
+}
+
+// Sampler polls /proc for the descendants of a root process. Counters are
+// kept per thread as last seen, so threads and processes living less than
+// the sampling interval, and the activity after the last sample, are
+// missed: the totals are lower bounds.
+type Sampler struct {
+	Interval time.Duration
+
+	mu         sync.Mutex
+	root       int
+	tasks      map[int]*taskStats
+	processes  map[int]bool
+	maxThreads int
+	done, wait chan struct{}
+}
+
+// New returns a sampler polling at the given interval.
+func New(interval time.Duration) *Sampler {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Sampler{Interval: interval}
+}
+
+// Available reports whether the kernel exposes per-task scheduler stats.
+func Available() bool {
+	_, err := os.Stat("/proc/self/schedstat")
+	return err == nil
+}
+
+// Start begins sampling the process tree rooted at pid.
+func (s *Sampler) Start(pid int) {
+	s.mu.Lock()
+	s.root = pid
+	s.tasks = make(map[int]*taskStats)
+	s.processes = make(map[int]bool)
+	s.maxThreads = 0
+	s.done = make(chan struct{})
+	s.wait = make(chan struct{})
+	s.mu.Unlock()
+
+	go s.run(s.done, s.wait)
+}
+
+func (s *Sampler) run(done, wait chan struct{}) {
+	defer close(wait)
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		s.sample()
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop ends sampling and returns the totals over the process tree, in the
+// same model as the eBPF metrics.
+func (s *Sampler) Stop() ebpf.Metrics {
+	if s.done == nil {
+		return ebpf.Metrics{}
+	}
+	close(s.done)
+	<-s.wait
+	s.done = nil
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := ebpf.Metrics{
+		Source:     ebpf.SourceProc,
+		Scope:      ebpf.ScopeProcessTree,
+		Processes:  len(s.processes),
+		MaxThreads: s.maxThreads,
+	}
+	var waitNs, timeslices int64
+	for _, t := range s.tasks {
+		waitNs += t.waitNs
+		timeslices += t.timeslices
+		m.VoluntarySwitches += t.voluntary
+		m.InvoluntarySwitches += t.involuntary
+		m.ReadBytes += t.readBytes
+		m.WriteBytes += t.writeBytes
+		m.ReadChars += t.readChars
+		m.WriteChars += t.writeChars
+	}
+	m.RunqueueWaitMs = float64(waitNs) / 1e6
+	if timeslices > 0 {
+		m.RunqueueLatencyUs = float64(waitNs) / float64(timeslices) / 1000
+	}
+	return m
+}
+
+// sample reads the counters of every thread currently in the tree.
+func (s *Sampler) sample() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	threads := 0
+	for _, pid := range descendants(s.root) {
+		s.processes[pid] = true
+		tids, err := taskIDs(pid)
+		if err != nil {
+			continue
+		}
+		for _, tid := range tids {
+			t, ok := s.tasks[tid]
+			if !ok {
+				t = &taskStats{}
+				s.tasks[tid] = t
+			}
+			dir := fmt.Sprintf("/proc/%d/task/%d", pid, tid)
+			// A read failing means the task just exited: keep its last values
+			if run, wait, slices, err := readSchedstat(dir); err == nil {
+				t.runNs, t.waitNs, t.timeslices = run, wait, slices
+			}
+			if status, err := readKeyValues(filepath.Join(dir, "status")); err == nil {
+				t.voluntary = status["voluntary_ctxt_switches"]
+				t.involuntary = status["nonvoluntary_ctxt_switches"]
+			}
+			if tid == pid {
+				if io, err := readKeyValues(fmt.Sprintf("/proc/%d/io", pid)); err == nil {
+					t.readBytes, t.writeBytes = io["read_bytes"], io["write_bytes"]
+					t.readChars, t.writeChars = io["rchar"], io["wchar"]
+				}
+			}
+		}
+		threads += len(tids)
+	}
+	if threads > s.maxThreads {
+		s.maxThreads = threads
+	}
+}
+
+// descendants returns root and all its descendant processes, through the
+// children lists of their threads (or a scan of /proc on kernels without
+// them).
+func descendants(root int) []int {
+	if _, err := os.Stat(fmt.Sprintf("/proc/%d/task/%d/children", root, root)); err != nil {
+		return scanDescendants(root)
+	}
+	pids := []int{root}
+	for i := 0; i < len(pids); i++ {
+		tids, err := taskIDs(pids[i])
+		if err != nil {
+			continue
+		}
+		for _, tid := range tids {
+			data, err := os.ReadFile(fmt.Sprintf("/proc/%d/task/%d/children", pids[i], tid))
+			if err != nil {
+				continue
+			}
+			for _, field := range strings.Fields(string(data)) {
+				if child, err := strconv.Atoi(field); err == nil {
+					pids = append(pids, child)
+				}
+			}
+		}
+	}
+	return pids
+}
+
+// scanDescendants finds the descendants of root from the parent PIDs of
+// every process.
+func scanDescendants(root int) []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	children := make(map[int][]int)
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			continue
+		}
+		// The command name may contain spaces: fields start after ")"
+		stat := string(data)
+		fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+		if len(fields) < 2 {
+			continue
+		}
+		if ppid, err := strconv.Atoi(fields[1]); err == nil {
+			children[ppid] = append(children[ppid], pid)
+		}
+	}
+
+	pids := []int{root}
+	for i := 0; i < len(pids); i++ {
+		pids = append(pids, children[pids[i]]...)
+	}
+	return pids
+}
+
+func taskIDs(pid int) ([]int, error) {
+	entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/task", pid))
+	if err != nil {
+		return nil, err
+	}
+	tids := make([]int, 0, len(entries))
+	for _, e := range entries {
+		if tid, err := strconv.Atoi(e.Name()); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+	return tids, nil
+}
+
+// readSchedstat reads the time on CPU, the time waiting on a runqueue (both
+// in nanoseconds) and the number of timeslices of a task.
+func readSchedstat(dir string) (int64, int64, int64, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "schedstat"))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return 0, 0, 0, fmt.Errorf("unexpected schedstat format")
+	}
+	var values [3]int64
+	for i := range values {
+		if values[i], err = strconv.ParseInt(fields[i], 10, 64); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	return values[0], values[1], values[2], nil
+}
+
+// readKeyValues parses "key: value" files such as status and io, keeping
+// the integer values.
+func readKeyValues(path string) (map[string]int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]int64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+			values[key] = n
+		}
+	}
+	return values, scanner.Err()
+}
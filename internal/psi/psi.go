@@ -0,0 +1,96 @@
+// Package psi reads Linux Pressure Stall Information: the time tasks were
+// stalled waiting for CPU, memory or I/O, system-wide from /proc/pressure or
+// for one cgroup v2 group from its *.pressure files.
+package psi
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Resources with pressure information.
+var Resources = []string{"cpu", "memory", "io"}
+
+// Stall is the cumulative stall time of one resource, in microseconds.
+// Some is the time at least one task was stalled, Full the time all
+// non-idle tasks were; HasFull is false where the kernel does not report it
+// (system-wide CPU before 5.13).
+type Stall struct {
+	Some, Full uint64
+	HasFull    bool
+}
+
+// Snapshot holds the stall totals of every resource at one point in time.
+type Snapshot map[string]Stall
+
+// Available reports whether the kernel exposes PSI.
+func Available() bool {
+	_, err := os.Stat("/proc/pressure/cpu")
+	return err == nil
+}
+
+// Read snapshots the stall totals of a cgroup directory, or system-wide
+// when dir is empty.
+func Read(dir string) (Snapshot, error) {
+	s := make(Snapshot)
+	for _, res := range Resources {
+		path := filepath.Join("/proc/pressure", res)
+		if dir != "" {
+			path = filepath.Join(dir, res+".pressure")
+		}
+		stall, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		s[res] = stall
+	}
+	return s, nil
+}
+
+// Sub returns the stall time accumulated since an earlier snapshot.
+func (s Snapshot) Sub(before Snapshot) Snapshot {
+	delta := make(Snapshot)
+	for res, after := range s {
+		b := before[res]
+		delta[res] = Stall{
+			Some:    after.Some - b.Some,
+			Full:    after.Full - b.Full,
+			HasFull: after.HasFull,
+		}
+	}
+	return delta
+}
+
+// readFile parses "some|full avg10=... avg60=... avg300=... total=N" lines.
+func readFile(path string) (Stall, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Stall{}, err
+	}
+	defer f.Close()
+
+	var stall Stall
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var total uint64
+		for _, field := range fields[1:] {
+			if v, ok := strings.CutPrefix(field, "total="); ok {
+				total, _ = strconv.ParseUint(v, 10, 64)
+			}
+		}
+		switch fields[0] {
+		case "some":
+			stall.Some = total
+		case "full":
+			stall.Full, stall.HasFull = total, true
+		}
+	}
+	return stall, scanner.Err()
+}
@@ -5,6 +5,8 @@ import (
 	"html/template"
 	"os"
 	"strings"
+
+	"github.com/processgain/internal/ebpf"
 )
 
 const singleReportTemplate = `<!DOCTYPE html>
@@ -30,19 +32,51 @@ const singleReportTemplate = `<!DOCTYPE html>
             {{if .Tag}}<p class="text-gray-500">Tag: {{.Tag}}</p>{{end}}
         </div>
 
+        {{if not .Valid}}
+        <div class="bg-red-50 border border-red-300 text-red-800 rounded-xl p-4 mb-8 text-center">
+            <strong>INVALID REPORT:</strong> the scenarios did not produce the same output, so the gain compares different work. See Output Verification below.
+        </div>
+        {{end}}
+
         <!-- Main Gain Card -->
         <div class="bg-white rounded-xl shadow-lg p-8 mb-8 text-center">
             <h2 class="text-2xl font-semibold text-gray-700 mb-4">Performance Gain</h2>
+            {{if .Comparison.Missing}}
+            <div class="text-6xl font-bold mb-4 text-gray-400">n/a</div>
+            <p class="text-gray-600 mb-2">A scenario has no successful run to compare.</p>
+            {{else}}
+            {{if .Comparison.GainUndefined}}
+            <div class="text-6xl font-bold mb-4 text-gray-400">n/a</div>
+            {{else}}
             <div class="text-6xl font-bold mb-4 {{if ge .Comparison.GainPercent 0.0}}gain-positive{{else}}gain-negative{{end}}">
                 {{printf "%.2f" .Comparison.GainPercent}}%
             </div>
+            {{end}}
             <p class="text-gray-600 mb-2">
-                Baseline median: <strong>{{printf "%.2f" .Baseline.Stats.Median}}ms</strong> → 
-                Optimized median: <strong>{{printf "%.2f" .Optimized.Stats.Median}}ms</strong>
+                Baseline median: <strong>{{printf "%.2f" .Baseline.Stats.Median}} {{.Unit}}</strong> → 
+                {{if .Candidates}}{{(index .Candidates 0).Name}}{{else}}Optimized{{end}} median: <strong>{{printf "%.2f" .Optimized.Stats.Median}} {{.Unit}}</strong>
+            </p>
+            {{if .Comparison.GainUndefined}}
+            <p class="text-gray-500">A baseline run is zero or negative: a percentage of it means nothing.</p>
+            {{else}}
+            <p class="text-gray-500">
+                {{printf "%.0f" (mul100 .Comparison.Confidence)}}% CI of gain ({{.Comparison.CIMethod}} bootstrap): [{{printf "%.2f" .Comparison.GainCILow}}%, {{printf "%.2f" .Comparison.GainCIHigh}}%]
             </p>
             <p class="text-gray-500">
                 P10/P90 of gain: {{printf "%.2f" .Comparison.GainP10}}% / {{printf "%.2f" .Comparison.GainP90}}%
             </p>
+            {{end}}
+            <p class="text-gray-500">
+                {{.Comparison.Test}}: p = {{printf "%.4f" .Comparison.PValue}} |
+                Cliff's delta: {{printf "%.2f" .Comparison.CliffsDelta}} |
+                Hodges-Lehmann shift: {{printf "%+.2f" .Comparison.HodgesLehmann}} {{.Unit}}
+            </p>
+            {{end}}
+            {{if .Comparison.Correction}}
+            <p class="text-gray-500">
+                Adjusted p ({{.Comparison.Correction}}): {{printf "%.4f" .Comparison.AdjustedP}}
+            </p>
+            {{end}}
             <div class="mt-4">
                 {{if .Comparison.Conclusive}}
                 <span class="inline-flex items-center px-4 py-2 rounded-full bg-green-100 text-green-800">
@@ -50,48 +84,185 @@ const singleReportTemplate = `<!DOCTYPE html>
                 </span>
                 {{else}}
                 <span class="inline-flex items-center px-4 py-2 rounded-full bg-yellow-100 text-yellow-800">
-                    ⚠ Inconclusive (high variance or overlap)
+                    ⚠ Inconclusive (not significant, negligible effect or CI includes 0)
                 </span>
                 {{end}}
             </div>
+            {{if .Verdict.Outcome}}
+            <div class="mt-4">
+                {{if eq .Verdict.Outcome "better"}}
+                <span class="inline-flex items-center px-4 py-2 rounded-full bg-green-100 text-green-800">Verdict: better</span>
+                {{else if eq .Verdict.Outcome "worse"}}
+                <span class="inline-flex items-center px-4 py-2 rounded-full bg-red-100 text-red-800">Verdict: worse</span>
+                {{else if eq .Verdict.Outcome "tradeoff"}}
+                <span class="inline-flex items-center px-4 py-2 rounded-full bg-orange-100 text-orange-800">Verdict: trade-off</span>
+                {{else}}
+                <span class="inline-flex items-center px-4 py-2 rounded-full bg-gray-100 text-gray-800">Verdict: neutral</span>
+                {{end}}
+                <p class="text-gray-600 mt-2">{{.Verdict.Summary}}</p>
+            </div>
+            {{end}}
         </div>
 
+        <!-- Candidate Ranking -->
+        {{if .Candidates}}
+        <div class="bg-white rounded-xl shadow-lg p-6 mb-8">
+            <h3 class="text-xl font-semibold text-gray-700 mb-4">Candidate Ranking</h3>
+            <p class="text-sm text-gray-500 mb-4">Each candidate vs the baseline (median {{printf "%.2f" .Baseline.Stats.Median}} {{.Unit}}); p-values adjusted for multiple comparisons. The figures above are for the top-ranked candidate.</p>
+            <table class="w-full text-sm">
+                <thead>
+                    <tr class="border-b-2 border-gray-200">
+                        <th class="py-2 text-left">Rank</th>
+                        <th class="py-2 text-left">Scenario</th>
+                        <th class="py-2 text-right">Median</th>
+                        <th class="py-2 text-right">Gain %</th>
+                        <th class="py-2 text-right">CI %</th>
+                        <th class="py-2 text-right">p (adj)</th>
+                        <th class="py-2 text-right">Cliff's δ</th>
+                        <th class="py-2 text-center">Conclusive</th>
+                        <th class="py-2 text-center">Verdict</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .Candidates}}
+                    <tr class="border-b">
+                        <td class="py-2">{{.Rank}}</td>
+                        <td class="py-2 font-medium">{{.Name}} <code class="text-xs text-gray-500">{{.Script}}</code></td>
+                        <td class="py-2 text-right font-mono">{{printf "%.2f" .Scenario.Stats.Median}} {{$.Unit}}</td>
+                        {{if .Comparison.HasGain}}
+                        <td class="py-2 text-right font-mono {{if ge .Comparison.GainPercent 0.0}}gain-positive{{else}}gain-negative{{end}}">{{printf "%+.2f" .Comparison.GainPercent}}%</td>
+                        <td class="py-2 text-right font-mono">[{{printf "%.2f" .Comparison.GainCILow}}, {{printf "%.2f" .Comparison.GainCIHigh}}]</td>
+                        {{else}}
+                        <td class="py-2 text-right text-gray-400">{{if .Comparison.Missing}}missing{{else}}n/a{{end}}</td>
+                        <td class="py-2 text-right text-gray-400">-</td>
+                        {{end}}
+                        <td class="py-2 text-right font-mono">{{if .Comparison.Missing}}-{{else}}{{printf "%.4f" .Comparison.AdjustedP}}{{end}}</td>
+                        <td class="py-2 text-right font-mono">{{printf "%.2f" .Comparison.CliffsDelta}}</td>
+                        <td class="py-2 text-center">{{if .Comparison.Conclusive}}<span class="text-green-600">✓</span>{{else}}<span class="text-yellow-600">?</span>{{end}}</td>
+                        <td class="py-2 text-center uppercase">{{.Verdict.Outcome}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
+
         <!-- Statistics Comparison -->
         <div class="grid md:grid-cols-2 gap-6 mb-8">
             <div class="bg-white rounded-xl shadow-lg p-6">
                 <h3 class="text-xl font-semibold text-gray-700 mb-4">Baseline Statistics</h3>
                 <table class="w-full">
-                    <tr class="border-b"><td class="py-2 text-gray-600">Median</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Baseline.Stats.Median}} ms</td></tr>
-                    <tr class="border-b"><td class="py-2 text-gray-600">Mean</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Baseline.Stats.Mean}} ms</td></tr>
-                    <tr class="border-b"><td class="py-2 text-gray-600">Std Dev</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Baseline.Stats.StdDev}} ms</td></tr>
+                    <tr class="border-b"><td class="py-2 text-gray-600">Median</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Baseline.Stats.Median}} {{$.Unit}}</td></tr>
+                    <tr class="border-b"><td class="py-2 text-gray-600">Mean</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Baseline.Stats.Mean}} {{$.Unit}}</td></tr>
+                    <tr class="border-b"><td class="py-2 text-gray-600">Std Dev</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Baseline.Stats.StdDev}} {{$.Unit}}</td></tr>
                     <tr class="border-b"><td class="py-2 text-gray-600">CV</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Baseline.Stats.CV}}%</td></tr>
-                    <tr class="border-b"><td class="py-2 text-gray-600">P10</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Baseline.Stats.P10}} ms</td></tr>
-                    <tr><td class="py-2 text-gray-600">P90</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Baseline.Stats.P90}} ms</td></tr>
+                    <tr class="border-b"><td class="py-2 text-gray-600">P10</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Baseline.Stats.P10}} {{$.Unit}}</td></tr>
+                    <tr><td class="py-2 text-gray-600">P90</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Baseline.Stats.P90}} {{$.Unit}}</td></tr>
                 </table>
             </div>
             <div class="bg-white rounded-xl shadow-lg p-6">
                 <h3 class="text-xl font-semibold text-gray-700 mb-4">Optimized Statistics</h3>
                 <table class="w-full">
-                    <tr class="border-b"><td class="py-2 text-gray-600">Median</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Optimized.Stats.Median}} ms</td></tr>
-                    <tr class="border-b"><td class="py-2 text-gray-600">Mean</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Optimized.Stats.Mean}} ms</td></tr>
-                    <tr class="border-b"><td class="py-2 text-gray-600">Std Dev</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Optimized.Stats.StdDev}} ms</td></tr>
+                    <tr class="border-b"><td class="py-2 text-gray-600">Median</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Optimized.Stats.Median}} {{$.Unit}}</td></tr>
+                    <tr class="border-b"><td class="py-2 text-gray-600">Mean</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Optimized.Stats.Mean}} {{$.Unit}}</td></tr>
+                    <tr class="border-b"><td class="py-2 text-gray-600">Std Dev</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Optimized.Stats.StdDev}} {{$.Unit}}</td></tr>
                     <tr class="border-b"><td class="py-2 text-gray-600">CV</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Optimized.Stats.CV}}%</td></tr>
-                    <tr class="border-b"><td class="py-2 text-gray-600">P10</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Optimized.Stats.P10}} ms</td></tr>
-                    <tr><td class="py-2 text-gray-600">P90</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Optimized.Stats.P90}} ms</td></tr>
+                    <tr class="border-b"><td class="py-2 text-gray-600">P10</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Optimized.Stats.P10}} {{$.Unit}}</td></tr>
+                    <tr><td class="py-2 text-gray-600">P90</td><td class="py-2 font-mono text-right">{{printf "%.2f" .Optimized.Stats.P90}} {{$.Unit}}</td></tr>
                 </table>
             </div>
         </div>
 
+        {{with .StrayRuns}}
+        <div class="bg-yellow-50 border border-yellow-300 text-yellow-800 rounded-xl p-4 mb-8">
+            ⚠ {{.}} run(s) left processes running after the script exited; they were terminated. See <code>stray_processes</code> in the JSON report.
+        </div>
+        {{end}}
+
         <!-- Duration Chart -->
         <div class="bg-white rounded-xl shadow-lg p-6 mb-8">
-            <h3 class="text-xl font-semibold text-gray-700 mb-4">Run Durations</h3>
+            <h3 class="text-xl font-semibold text-gray-700 mb-4">Measured Runs</h3>
             <canvas id="durationsChart" height="100"></canvas>
         </div>
 
+        {{if .PhaseBreakdown}}
+        <!-- Phases Chart -->
+        <div class="bg-white rounded-xl shadow-lg p-6 mb-8">
+            <h3 class="text-xl font-semibold text-gray-700 mb-4">Phases</h3>
+            <p class="text-sm text-gray-500 mb-4">Median time of each phase marked with <code>CORECUT_PHASE</code>, per scenario; the per-phase comparisons are in All Metrics</p>
+            <canvas id="phasesChart" height="60"></canvas>
+        </div>
+        {{end}}
+
+        <!-- All Metrics -->
+        {{if .Metrics}}
+        <div class="bg-white rounded-xl shadow-lg p-6 mb-8">
+            <h3 class="text-xl font-semibold text-gray-700 mb-4">All Metrics (median per run)</h3>
+            <p class="text-sm text-gray-500 mb-4">Key metrics (★) decide the verdict; resource usage covers the whole process tree</p>
+            <table class="w-full text-sm">
+                <thead>
+                    <tr class="border-b-2 border-gray-200">
+                        <th class="py-2 text-left">Metric</th>
+                        <th class="py-2 text-right">Baseline</th>
+                        <th class="py-2 text-right">Optimized</th>
+                        <th class="py-2 text-right">Gain %</th>
+                        <th class="py-2 text-right">CI %</th>
+                        <th class="py-2 text-right">p</th>
+                        <th class="py-2 text-right">Cliff's δ</th>
+                        <th class="py-2 text-center">Conclusive</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .Metrics}}
+                    <tr class="border-b">
+                        <td class="py-2 text-gray-600">{{if .Key}}★ {{end}}{{.Label}} <span class="text-gray-400">({{.Comparison.Direction}} is better)</span></td>
+                        <td class="py-2 font-mono text-right">{{printf "%.2f" .Baseline.Median}} {{.Unit}}</td>
+                        <td class="py-2 font-mono text-right">{{printf "%.2f" .Optimized.Median}} {{.Unit}}</td>
+                        {{if .Comparison.HasGain}}
+                        <td class="py-2 font-mono text-right {{if ge .Comparison.GainPercent 0.0}}gain-positive{{else}}gain-negative{{end}}">{{printf "%.2f" .Comparison.GainPercent}}%</td>
+                        <td class="py-2 font-mono text-right">[{{printf "%.1f" .Comparison.GainCILow}}, {{printf "%.1f" .Comparison.GainCIHigh}}]</td>
+                        {{else}}
+                        <td class="py-2 text-right text-gray-400">{{if .Comparison.Missing}}missing{{else}}n/a{{end}}</td>
+                        <td class="py-2 text-right text-gray-400">-</td>
+                        {{end}}
+                        <td class="py-2 font-mono text-right">{{if .Comparison.Missing}}-{{else}}{{printf "%.3f" .Comparison.PValue}}{{end}}</td>
+                        <td class="py-2 font-mono text-right">{{printf "%.2f" .Comparison.CliffsDelta}}</td>
+                        <td class="py-2 text-center">{{if .Comparison.Conclusive}}<span class="text-green-600">✓</span>{{else}}<span class="text-yellow-600">?</span>{{end}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
+
         <!-- eBPF Insights -->
-        {{if .Baseline.Ebpf}}
+        {{if .Baseline.Ebpf}}{{with .Ebpf}}
         <div class="bg-white rounded-xl shadow-lg p-6 mb-8">
-            <h3 class="text-xl font-semibold text-gray-700 mb-4">eBPF Insights (Where did the time go?)</h3>
+            <h3 class="text-xl font-semibold text-gray-700 mb-1">{{if eq .Source "proc"}}Process Insights{{else}}eBPF Insights{{end}} (Where did the time go?)</h3>
+            <p class="text-sm text-gray-500 mb-4">{{if eq .Scope "cgroup"}}Scoped to each scenario's process tree (per-scenario cgroup){{else if eq .Scope "process-tree"}}Sampled from /proc over each scenario's process tree; processes shorter than the sampling interval are missed{{else}}System-wide: includes activity outside the scenarios{{end}}</p>
+            {{if .Counters}}
+            <table class="w-full text-sm mb-6">
+                <thead>
+                    <tr class="border-b text-left text-gray-600">
+                        <th class="py-2">Metric (mean per run)</th>
+                        <th class="py-2 text-right">Baseline</th>
+                        <th class="py-2 text-right">Optimized</th>
+                        <th class="py-2 text-right">Change</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .Counters}}
+                    <tr class="border-b">
+                        <td class="py-2">{{.Name}}{{if ne .Unit "count"}} ({{.Unit}}){{end}}</td>
+                        <td class="py-2 text-right">{{printf "%.2f" .Baseline}}</td>
+                        <td class="py-2 text-right">{{printf "%.2f" .Optimized}}</td>
+                        <td class="py-2 text-right">{{if .Baseline}}{{printf "%+.1f" .DeltaPercent}}%{{else}}-{{end}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+            {{end}}
+            {{if ne .Source "proc"}}
             <div class="grid md:grid-cols-2 gap-4">
                 <div>
                     <h4 class="font-semibold text-gray-600 mb-2">Runqueue Latency</h4>
@@ -104,6 +275,177 @@ const singleReportTemplate = `<!DOCTYPE html>
                     <canvas id="biolatChart" height="150"></canvas>
                 </div>
             </div>
+            {{end}}
+            {{if .OffCpuStacks}}
+            <h4 class="font-semibold text-gray-600 mt-6 mb-2">Off-CPU Time: {{printf "%.2f" .OffCpuBaselineMs}}ms → {{printf "%.2f" .OffCpuOptimizedMs}}ms per run</h4>
+            <p class="text-sm text-gray-500 mb-2">Blocking stacks with the largest change (user frames, then kernel frames after "-")</p>
+            <table class="w-full text-sm">
+                <thead>
+                    <tr class="border-b text-left text-gray-600">
+                        <th class="py-2">Stack</th>
+                        <th class="py-2 text-right">Baseline</th>
+                        <th class="py-2 text-right">Optimized</th>
+                        <th class="py-2 text-right">Δ</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .OffCpuStacks}}
+                    <tr class="border-b align-top">
+                        <td class="py-2"><details><summary class="font-mono cursor-pointer">{{shortStack .Stack}}</summary><p class="mt-1 font-mono text-xs text-gray-500 break-all">{{.Stack}}</p></details></td>
+                        <td class="py-2 text-right whitespace-nowrap">{{printf "%.2f" .BaselineMs}}ms ({{printf "%.0f" .BaselinePercent}}%)</td>
+                        <td class="py-2 text-right whitespace-nowrap">{{printf "%.2f" .OptimizedMs}}ms ({{printf "%.0f" .OptimizedPercent}}%)</td>
+                        <td class="py-2 text-right whitespace-nowrap {{if lt .DeltaMs 0.0}}text-green-600{{else}}text-red-600{{end}}">{{printf "%+.2f" .DeltaMs}}ms</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+            {{end}}{{if .Syscalls}}
+            <h4 class="font-semibold text-gray-600 mt-6 mb-2">Syscalls</h4>
+            <p class="text-sm text-gray-500 mb-2">Mean count and total time per run, sorted by impact</p>
+            <table class="w-full text-sm">
+                <thead>
+                    <tr class="border-b text-left text-gray-600">
+                        <th class="py-2">Syscall</th>
+                        <th class="py-2 text-right">Count</th>
+                        <th class="py-2 text-right">Δ Count</th>
+                        <th class="py-2 text-right">Time (ms)</th>
+                        <th class="py-2 text-right">Δ Time (ms)</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .Syscalls}}
+                    <tr class="border-b">
+                        <td class="py-2 font-mono">{{.Name}}</td>
+                        <td class="py-2 text-right">{{printf "%.0f" .BaselineCount}} → {{printf "%.0f" .OptimizedCount}}</td>
+                        <td class="py-2 text-right {{if lt .CountDelta 0.0}}text-green-600{{else}}text-red-600{{end}}">{{printf "%+.0f" .CountDelta}}</td>
+                        <td class="py-2 text-right">{{printf "%.2f" (div1000 .BaselineLatencyUs)}} → {{printf "%.2f" (div1000 .OptimizedLatencyUs)}}</td>
+                        <td class="py-2 text-right {{if lt .LatencyDeltaUs 0.0}}text-green-600{{else}}text-red-600{{end}}">{{printf "%+.2f" (div1000 .LatencyDeltaUs)}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+            {{end}}
+        </div>
+        {{end}}{{end}}
+
+        <!-- Collector sections -->
+        {{range .Sections}}
+        <div class="bg-white rounded-xl shadow-lg p-6 mb-8">
+            <h3 class="text-xl font-semibold text-gray-700 mb-1">{{.Title}}</h3>
+            <p class="text-sm text-gray-500 mb-4">Collector <code>{{.Collector}}</code>, mean per run</p>
+            <table class="w-full text-sm">
+                <thead>
+                    <tr class="border-b text-left text-gray-600">
+                        <th class="py-2">Value</th>
+                        <th class="py-2 text-right">Baseline</th>
+                        <th class="py-2 text-right">Optimized</th>
+                        <th class="py-2 text-right">Change</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .Rows}}
+                    <tr class="border-b">
+                        <td class="py-2">{{.Name}}{{if .Unit}} ({{.Unit}}){{end}}</td>
+                        <td class="py-2 text-right">{{printf "%.4g" .Baseline}}</td>
+                        <td class="py-2 text-right">{{printf "%.4g" .Optimized}}</td>
+                        <td class="py-2 text-right {{if eq .Outcome "better"}}text-green-600{{else if eq .Outcome "worse"}}text-red-600{{end}}">{{if .Baseline}}{{printf "%+.1f" .DeltaPercent}}%{{else}}-{{end}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
+
+        {{with .Verification}}
+        <!-- Output Verification -->
+        <div class="bg-white rounded-xl shadow-lg p-6 mb-8">
+            <h3 class="text-xl font-semibold text-gray-700 mb-4">Output Verification</h3>
+            <p class="text-sm text-gray-500 mb-4">Output of every successful run compared with the baseline's first: {{.Method}}</p>
+            {{with .Error}}<p class="text-red-700 mb-4">✗ {{.}}</p>{{end}}
+            <table class="w-full text-sm">
+                <thead>
+                    <tr class="border-b-2 border-gray-200">
+                        <th class="py-2 text-left">Scenario</th>
+                        <th class="py-2 text-right">Checked</th>
+                        <th class="py-2 text-right">Mismatches</th>
+                        <th class="py-2 text-left pl-4">First difference</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .Scenarios}}
+                    <tr class="border-b align-top">
+                        <td class="py-2">{{.Scenario}}</td>
+                        <td class="py-2 text-right font-mono">{{.Checked}}</td>
+                        <td class="py-2 text-right font-mono {{if .Mismatches}}text-red-600{{else}}text-green-600{{end}}">{{.Mismatches}}</td>
+                        <td class="py-2 pl-4">{{if .Mismatches}}run {{.FirstMismatch}}<pre class="mt-1 bg-gray-100 p-2 rounded text-xs overflow-x-auto">{{.Excerpt}}</pre>{{else}}<span class="text-gray-400">—</span>{{end}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
+
+        {{if .Config.Artifacts}}
+        <!-- Run Output -->
+        <div class="bg-white rounded-xl shadow-lg p-6 mb-8">
+            <h3 class="text-xl font-semibold text-gray-700 mb-4">Run Output</h3>
+            <p class="text-sm text-gray-500 mb-4">Full output of every measured run, in <code>{{.Config.ArtifactDir}}</code> next to this report</p>
+            <table class="w-full text-sm">
+                <thead>
+                    <tr class="border-b-2 border-gray-200">
+                        <th class="py-2 text-left">Scenario</th>
+                        <th class="py-2 text-right">Run</th>
+                        <th class="py-2 text-right">Wall time</th>
+                        <th class="py-2 text-right">Exit</th>
+                        <th class="py-2 text-left pl-4">Error</th>
+                        <th class="py-2 text-left">Output</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .ScenarioRuns}}{{$name := .Name}}{{range $i, $r := .Runs}}
+                    <tr class="border-b{{if $r.Error}} bg-red-50{{end}}">
+                        <td class="py-2">{{$name}}</td>
+                        <td class="py-2 text-right">{{inc $i}}</td>
+                        <td class="py-2 text-right font-mono">{{printf "%.2f" $r.DurationMs}} ms</td>
+                        <td class="py-2 text-right font-mono">{{$r.ExitCode}}</td>
+                        <td class="py-2 pl-4 text-red-700">{{$r.Error}}</td>
+                        <td class="py-2">{{with $r.Artifacts}}<a class="text-blue-600 underline" href="{{.Stdout}}">stdout</a> · <a class="text-blue-600 underline" href="{{.Stderr}}">stderr</a> · <a class="text-blue-600 underline" href="{{.Combined}}">combined</a>{{if .Truncated}} <span class="text-yellow-700">(truncated)</span>{{end}}{{with .Error}} <span class="text-red-700">{{.}}</span>{{end}}{{else}}<span class="text-gray-400">none</span>{{end}}</td>
+                    </tr>
+                    {{end}}{{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
+
+        {{with .HookStats}}
+        <!-- Hooks -->
+        <div class="bg-white rounded-xl shadow-lg p-6 mb-8">
+            <h3 class="text-xl font-semibold text-gray-700 mb-4">Hooks</h3>
+            <p class="text-sm text-gray-500 mb-4">Commands run around the scenarios and their runs, warmups included; their time is not measured</p>
+            <table class="w-full text-sm">
+                <thead>
+                    <tr class="border-b-2 border-gray-200">
+                        <th class="py-2 text-left">Scenario</th>
+                        <th class="py-2 text-left">Hook</th>
+                        <th class="py-2 text-right">Runs</th>
+                        <th class="py-2 text-right">Failures</th>
+                        <th class="py-2 text-right">Mean time</th>
+                        <th class="py-2 text-left pl-4">First error</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .}}
+                    <tr class="border-b{{if .Failures}} bg-red-50{{end}}">
+                        <td class="py-2">{{.Scenario}}</td>
+                        <td class="py-2"><code>{{.Hook}}</code></td>
+                        <td class="py-2 text-right font-mono">{{.Runs}}</td>
+                        <td class="py-2 text-right font-mono {{if .Failures}}text-red-600{{end}}">{{.Failures}}</td>
+                        <td class="py-2 text-right font-mono">{{printf "%.2f" .MeanMs}} ms</td>
+                        <td class="py-2 pl-4 text-red-700">{{.Error}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
         </div>
         {{end}}
 
@@ -115,11 +457,30 @@ const singleReportTemplate = `<!DOCTYPE html>
                 <div><span class="text-gray-600">Optimized Script:</span> <code class="bg-gray-100 px-2 py-1 rounded">{{.Config.OptimizedScript}}</code></div>
                 <div><span class="text-gray-600">Mode:</span> {{.Config.Mode}}</div>
                 <div><span class="text-gray-600">Warmup Runs:</span> {{.Config.WarmupRuns}}</div>
-                <div><span class="text-gray-600">Measured Runs:</span> {{.Config.MeasuredRuns}}</div>
-                <div><span class="text-gray-600">Alternating:</span> {{.Config.Alternate}}</div>
+                <div><span class="text-gray-600">Measured Runs:</span> {{.Config.MeasuredRuns}}{{if .Sampling.Adaptive}} (adaptive, {{.Config.MinRuns}}-{{.Config.MaxRuns}}){{end}}</div>
+                <div><span class="text-gray-600">Sampling Stopped:</span> {{.Sampling.StopReason}} after {{.Sampling.Batches}} batch(es), {{printf "%.1f" .Sampling.ElapsedSec}}s</div>
+                <div><span class="text-gray-600">Alternating:</span> {{.Config.Alternate}}{{if and .Config.Alternate .Config.Order}} ({{.Config.Order}}, seed {{.Config.Seed}}){{end}}</div>
                 <div><span class="text-gray-600">Cooldown:</span> {{.Config.CooldownMs}}ms</div>
                 <div><span class="text-gray-600">Timeout:</span> {{.Config.Timeout}}s</div>
+                {{if .Config.CgroupMode}}<div><span class="text-gray-600">Cgroup:</span> per {{.Config.CgroupMode}}{{with .Config.CPUMax}}, cpu.max <code class="bg-gray-100 px-2 py-1 rounded">{{.}}</code>{{end}}{{with .Config.CPUSetCPUs}}, cpuset.cpus <code class="bg-gray-100 px-2 py-1 rounded">{{.}}</code>{{end}}{{with .Config.MemoryMax}}, memory.max <code class="bg-gray-100 px-2 py-1 rounded">{{.}}</code>{{end}}</div>{{end}}
+                {{if or .Config.CPUs .Config.Nice .Config.IONice}}<div><span class="text-gray-600">Scheduling:</span> {{with .Config.CPUs}}CPUs <code class="bg-gray-100 px-2 py-1 rounded">{{.}}</code>{{if $.Config.SameCores}} (same cores for every scenario){{end}} {{end}}{{with .Config.Nice}}nice {{.}} {{end}}{{with .Config.IONice}}ionice {{.}}{{end}}</div>{{end}}
+                {{if .Config.Artifacts}}<div><span class="text-gray-600">Artifacts:</span> <code class="bg-gray-100 px-2 py-1 rounded">{{.Config.ArtifactDir}}</code>{{with .Config.ArtifactMaxSize}}, {{.}} max per file{{end}}{{if .Config.ArtifactGzip}}, gzip{{end}}</div>{{end}}
+                {{with .Config.Hooks}}{{with .Setup}}<div><span class="text-gray-600">Setup:</span> <code class="bg-gray-100 px-2 py-1 rounded">{{.}}</code></div>{{end}}{{with .BeforeRun}}<div><span class="text-gray-600">Before Run:</span> <code class="bg-gray-100 px-2 py-1 rounded">{{.}}</code></div>{{end}}{{with .AfterRun}}<div><span class="text-gray-600">After Run:</span> <code class="bg-gray-100 px-2 py-1 rounded">{{.}}</code></div>{{end}}{{with .Teardown}}<div><span class="text-gray-600">Teardown:</span> <code class="bg-gray-100 px-2 py-1 rounded">{{.}}</code></div>{{end}}{{end}}
+                {{range $k, $v := .Config.ScenarioHooks}}<div><span class="text-gray-600">Hooks ({{$k}}):</span> {{with $v.Setup}}setup <code class="bg-gray-100 px-2 py-1 rounded">{{.}}</code> {{end}}{{with $v.BeforeRun}}before_run <code class="bg-gray-100 px-2 py-1 rounded">{{.}}</code> {{end}}{{with $v.AfterRun}}after_run <code class="bg-gray-100 px-2 py-1 rounded">{{.}}</code> {{end}}{{with $v.Teardown}}teardown <code class="bg-gray-100 px-2 py-1 rounded">{{.}}</code>{{end}}</div>{{end}}
+                <div><span class="text-gray-600">Collectors:</span> {{if .Config.Collect}}{{range $i, $c := .Config.Collect}}{{if $i}}, {{end}}{{$c}}{{end}}{{else}}none{{end}}</div>
+                <div><span class="text-gray-600">Verdict Threshold:</span> {{printf "%.1f" .Config.VerdictThreshold}}%</div>
+                <div><span class="text-gray-600">Bootstrap:</span> {{.Config.Resamples}} resamples, {{printf "%.0f" (mul100 .Config.Confidence)}}% confidence, seed {{.Config.Seed}}</div>
+                <div><span class="text-gray-600">Significance:</span> α = {{.Config.Alpha}}, min |Cliff's δ| = {{.Config.MinEffect}}</div>
+                {{if .Config.Suite}}<div><span class="text-gray-600">Suite:</span> {{.Config.Suite}} <span class="text-gray-500">({{.Config.SpecFile}})</span></div>{{end}}
+                {{if .Config.EnvFile}}<div><span class="text-gray-600">Env File:</span> <code class="bg-gray-100 px-2 py-1 rounded">{{.Config.EnvFile}}</code></div>{{end}}
+                {{range $k, $v := .Config.Env}}<div><span class="text-gray-600">Env:</span> <code class="bg-gray-100 px-2 py-1 rounded">{{$k}}={{$v}}</code></div>{{end}}
             </div>
+            {{if .Sampling.ExecutionOrder}}
+            <details class="mt-4 text-sm">
+                <summary class="text-gray-600 cursor-pointer">Execution order ({{len .Sampling.ExecutionOrder}} measured runs)</summary>
+                <p class="mt-2 font-mono text-gray-500">{{range $i, $s := .Sampling.ExecutionOrder}}{{if $i}} → {{end}}{{$s}}{{end}}</p>
+            </details>
+            {{end}}
         </div>
 
         <!-- Footer -->
@@ -130,9 +491,9 @@ const singleReportTemplate = `<!DOCTYPE html>
     </div>
 
     <script>
-        // Duration chart
-        const baselineDurations = [{{range .Baseline.Runs}}{{.DurationMs}},{{end}}];
-        const optimizedDurations = [{{range .Optimized.Runs}}{{.DurationMs}},{{end}}];
+        // Measured values chart (duration or throughput, depending on mode)
+        const baselineDurations = [{{range .Baseline.Values}}{{.}},{{end}}];
+        const optimizedDurations = [{{range .Optimized.Values}}{{.}},{{end}}];
         const labels = baselineDurations.map((_, i) => 'Run ' + (i + 1));
 
         new Chart(document.getElementById('durationsChart'), {
@@ -164,11 +525,40 @@ const singleReportTemplate = `<!DOCTYPE html>
                 scales: {
                     y: {
                         beginAtZero: false,
-                        title: { display: true, text: 'Duration (ms)' }
+                        title: { display: true, text: {{.Metric}} + ' (' + {{.Unit}} + ')' }
                     }
                 }
             }
         });
+        {{with .PhaseBreakdown}}
+
+        // Phases chart, one stacked bar per scenario
+        const phaseColors = ['#6366f1', '#10b981', '#f59e0b', '#ef4444', '#8b5cf6', '#06b6d4', '#ec4899', '#84cc16'];
+        new Chart(document.getElementById('phasesChart'), {
+            type: 'bar',
+            data: {
+                labels: [{{range .Scenarios}}{{.}},{{end}}],
+                datasets: [
+                    {{range $i, $p := .Phases}}{
+                        label: {{$p.Name}},
+                        data: [{{range $p.MedianMs}}{{.}},{{end}}],
+                        backgroundColor: {{if $p.Outside}}'#d1d5db'{{else}}phaseColors[{{$i}} % phaseColors.length]{{end}}
+                    },{{end}}
+                ]
+            },
+            options: {
+                indexAxis: 'y',
+                responsive: true,
+                plugins: {
+                    legend: { position: 'top' }
+                },
+                scales: {
+                    x: { stacked: true, title: { display: true, text: 'ms' } },
+                    y: { stacked: true }
+                }
+            }
+        });
+        {{end}}
     </script>
 </body>
 </html>`
@@ -224,8 +614,8 @@ const aggregateReportTemplate = `<!DOCTYPE html>
                         <tr class="border-b-2 border-gray-200">
                             <th class="py-3 px-4 text-left">Machine</th>
                             <th class="py-3 px-4 text-right">Gain %</th>
-                            <th class="py-3 px-4 text-right">Baseline (ms)</th>
-                            <th class="py-3 px-4 text-right">Optimized (ms)</th>
+                            <th class="py-3 px-4 text-right">Baseline</th>
+                            <th class="py-3 px-4 text-right">Optimized</th>
                             <th class="py-3 px-4 text-center">Verdict</th>
                             <th class="py-3 px-4 text-left">Tag</th>
                         </tr>
@@ -237,8 +627,8 @@ const aggregateReportTemplate = `<!DOCTYPE html>
                             <td class="py-3 px-4 text-right font-mono {{if ge .Comparison.GainPercent 0.0}}gain-positive{{else}}gain-negative{{end}}">
                                 {{printf "%.2f" .Comparison.GainPercent}}%
                             </td>
-                            <td class="py-3 px-4 text-right font-mono">{{printf "%.2f" .Baseline.Stats.Median}}</td>
-                            <td class="py-3 px-4 text-right font-mono">{{printf "%.2f" .Optimized.Stats.Median}}</td>
+                            <td class="py-3 px-4 text-right font-mono">{{printf "%.2f" .Baseline.Stats.Median}} {{.Unit}}</td>
+                            <td class="py-3 px-4 text-right font-mono">{{printf "%.2f" .Optimized.Stats.Median}} {{.Unit}}</td>
                             <td class="py-3 px-4 text-center">
                                 {{if .Comparison.Conclusive}}
                                 <span class="text-green-600">✓</span>
@@ -305,8 +695,84 @@ const aggregateReportTemplate = `<!DOCTYPE html>
 </body>
 </html>`
 
+const summaryReportTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>ProcessGain - Suite Summary</title>
+    <script src="https://cdn.tailwindcss.com"></script>
+    <style>
+        .gain-positive { color: #10b981; }
+        .gain-negative { color: #ef4444; }
+    </style>
+</head>
+<body class="bg-gray-100 min-h-screen">
+    <div class="container mx-auto px-4 py-8">
+        <!-- Header -->
+        <div class="text-center mb-8">
+            <h1 class="text-4xl font-bold text-gray-800 mb-2">ProcessGain - Suite Summary</h1>
+            <p class="text-gray-600">Machine: {{.Machine}} | Spec: {{.SpecFile}} | Generated: {{.GeneratedAt.Format "2006-01-02 15:04:05 UTC"}}</p>
+        </div>
+
+        <!-- Suites Table -->
+        <div class="bg-white rounded-xl shadow-lg p-6 mb-8">
+            <h3 class="text-xl font-semibold text-gray-700 mb-4">Suites</h3>
+            <div class="overflow-x-auto">
+                <table class="w-full text-sm">
+                    <thead>
+                        <tr class="border-b-2 border-gray-200">
+                            <th class="py-3 px-4 text-left">Suite</th>
+                            <th class="py-3 px-4 text-left">Metric</th>
+                            <th class="py-3 px-4 text-right">Gain %</th>
+                            <th class="py-3 px-4 text-center">Conclusive</th>
+                            <th class="py-3 px-4 text-center">Verdict</th>
+                            <th class="py-3 px-4 text-left">Report</th>
+                        </tr>
+                    </thead>
+                    <tbody>
+                        {{range .Suites}}
+                        <tr class="border-b hover:bg-gray-50">
+                            <td class="py-3 px-4 font-medium">{{.Name}}</td>
+                            {{if .Error}}
+                            <td class="py-3 px-4 text-red-600" colspan="5">Failed: {{.Error}}</td>
+                            {{else}}
+                            <td class="py-3 px-4">{{.Metric}}</td>
+                            <td class="py-3 px-4 text-right font-mono {{if ge .GainPercent 0.0}}gain-positive{{else}}gain-negative{{end}}">
+                                {{printf "%.2f" .GainPercent}}%
+                            </td>
+                            <td class="py-3 px-4 text-center">
+                                {{if .Conclusive}}
+                                <span class="text-green-600">✓</span>
+                                {{else}}
+                                <span class="text-yellow-600">?</span>
+                                {{end}}
+                            </td>
+                            <td class="py-3 px-4 text-center uppercase">{{.Verdict}}</td>
+                            <td class="py-3 px-4"><a class="text-blue-600 hover:underline" href="{{.HTMLReport}}">{{.HTMLReport}}</a></td>
+                            {{end}}
+                        </tr>
+                        {{end}}
+                    </tbody>
+                </table>
+            </div>
+        </div>
+
+        <!-- Footer -->
+        <div class="text-center text-gray-500 text-sm">
+            <p>Generated by ProcessGain</p>
+        </div>
+    </div>
+</body>
+</html>`
+
 func GenerateHTML(r Report, outputPath string) error {
-	tmpl, err := template.New("report").Parse(singleReportTemplate)
+	tmpl, err := template.New("report").Funcs(template.FuncMap{
+		"mul100":     func(f float64) float64 { return f * 100 },
+		"shortStack": func(s string) string { return ebpf.ShortStack(s, 3) },
+		"div1000":    func(f float64) float64 { return f / 1000 },
+		"inc":        func(i int) int { return i + 1 },
+	}).Parse(singleReportTemplate)
 	if err != nil {
 		return fmt.Errorf("failed to parse template: %w", err)
 	}
@@ -339,3 +805,19 @@ func GenerateAggregateHTML(r AggregateReport, outputPath string) error {
 
 	return tmpl.Execute(f, r)
 }
+
+// GenerateSummaryHTML writes the index page of a multi-suite spec run.
+func GenerateSummaryHTML(r SummaryIndex, outputPath string) error {
+	tmpl, err := template.New("summary").Parse(summaryReportTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer f.Close()
+
+	return tmpl.Execute(f, r)
+}
@@ -3,9 +3,11 @@ package report
 import (
 	"time"
 
+	"github.com/processgain/internal/collect"
 	"github.com/processgain/internal/ebpf"
 	"github.com/processgain/internal/executor"
 	"github.com/processgain/internal/stats"
+	"github.com/processgain/internal/verify"
 )
 
 type Report struct {
@@ -13,27 +15,322 @@ type Report struct {
 	GeneratedAt time.Time      `json:"generated_at"`
 	Machine     string         `json:"machine"`
 	Tag         string         `json:"tag,omitempty"`
+	Metric      string         `json:"metric"`
+	Unit        string         `json:"unit"`
 	Config      Config         `json:"config"`
 	Baseline    ScenarioResult `json:"baseline"`
-	Optimized   ScenarioResult `json:"optimized"`
-	Comparison  stats.Comparison `json:"comparison"`
+	// Optimized, Comparison, Metrics and Verdict describe the top-ranked
+	// candidate when several are compared; Candidates then holds all of them.
+	Optimized  ScenarioResult    `json:"optimized"`
+	Comparison stats.Comparison  `json:"comparison"`
+	Sampling   Sampling          `json:"sampling"`
+	Metrics    []MetricResult    `json:"metrics,omitempty"`
+	Verdict    Verdict           `json:"verdict"`
+	Ebpf       *ebpf.Comparison  `json:"ebpf_comparison,omitempty"`
+	Sections   []collect.Section `json:"sections,omitempty"`
+	Candidates []CandidateResult `json:"candidates,omitempty"`
+	// Verification, when enabled, tells whether every scenario produced the
+	// baseline's output; a report that failed it is invalid
+	Verification *verify.Summary `json:"verification,omitempty"`
+}
+
+// Valid reports whether the report passed the output verification, if any.
+func (r Report) Valid() bool {
+	return r.Verification == nil || r.Verification.Passed
+}
+
+// ScenarioRuns are the measured runs of one scenario.
+type ScenarioRuns struct {
+	Name   string
+	Runs   []executor.RunResult
+	Result *ScenarioResult
+}
+
+// ScenarioRuns lists the runs of every scenario, baseline first.
+func (r Report) ScenarioRuns() []ScenarioRuns {
+	runs := []ScenarioRuns{{Name: "baseline", Runs: r.Baseline.Runs, Result: &r.Baseline}}
+	if len(r.Candidates) == 0 {
+		return append(runs, ScenarioRuns{Name: "optimized", Runs: r.Optimized.Runs, Result: &r.Optimized})
+	}
+	for i := range r.Candidates {
+		c := &r.Candidates[i]
+		runs = append(runs, ScenarioRuns{Name: c.Name, Runs: c.Scenario.Runs, Result: &c.Scenario})
+	}
+	return runs
+}
+
+// PhaseBreakdown is the median time of each phase the scripts marked, per
+// scenario, for the stacked bar chart.
+type PhaseBreakdown struct {
+	Scenarios []string
+	Phases    []PhaseSeries
+}
+
+// PhaseSeries holds the median duration of one phase in every scenario, in
+// the order of PhaseBreakdown.Scenarios (0 when a scenario did not mark it).
+type PhaseSeries struct {
+	Name     string
+	MedianMs []float64
+	// Outside is the series of the time not covered by any phase
+	Outside bool
+}
+
+// PhaseBreakdown returns the phases of the successful measured runs, in the
+// order they were first seen, or nil when no script marked any.
+func (r Report) PhaseBreakdown() *PhaseBreakdown {
+	scenarios := r.ScenarioRuns()
+	b := &PhaseBreakdown{}
+	index := make(map[string]int)
+	for _, s := range scenarios {
+		for _, run := range s.Runs {
+			for _, p := range run.Phases {
+				if _, ok := index[p.Name]; !ok {
+					index[p.Name] = len(b.Phases)
+					b.Phases = append(b.Phases, PhaseSeries{Name: p.Name, MedianMs: make([]float64, len(scenarios))})
+				}
+			}
+		}
+	}
+	if len(b.Phases) == 0 {
+		return nil
+	}
+
+	outside := PhaseSeries{Name: "(outside phases)", MedianMs: make([]float64, len(scenarios)), Outside: true}
+	for i, s := range scenarios {
+		b.Scenarios = append(b.Scenarios, s.Name)
+		durations := make([][]float64, len(b.Phases))
+		var rest []float64
+		for _, run := range s.Runs {
+			if run.Error != "" || len(run.Phases) == 0 {
+				continue
+			}
+			marked := 0.0
+			for _, p := range run.Phases {
+				durations[index[p.Name]] = append(durations[index[p.Name]], p.DurationMs)
+				marked += p.DurationMs
+			}
+			rest = append(rest, max(run.DurationMs-marked, 0))
+		}
+		for j, values := range durations {
+			if len(values) > 0 {
+				b.Phases[j].MedianMs[i] = stats.Calculate(values).Median
+			}
+		}
+		if len(rest) > 0 {
+			outside.MedianMs[i] = stats.Calculate(rest).Median
+		}
+	}
+	b.Phases = append(b.Phases, outside)
+	return b
+}
+
+// HookStat sums up the invocations of one hook of a scenario.
+type HookStat struct {
+	Scenario string
+	Hook     string
+	Runs     int
+	Failures int
+	MeanMs   float64
+	// Error is the first failure, if any
+	Error string
+}
+
+// HookStats lists the hooks that ran, per scenario in the order they run.
+func (r Report) HookStats() []HookStat {
+	var hookStats []HookStat
+	for _, s := range r.ScenarioRuns() {
+		byHook := make(map[string]*HookStat)
+		add := func(h executor.HookResult) {
+			st, ok := byHook[h.Hook]
+			if !ok {
+				st = &HookStat{Scenario: s.Name, Hook: h.Hook}
+				byHook[h.Hook] = st
+			}
+			st.MeanMs += (h.DurationMs - st.MeanMs) / float64(st.Runs+1)
+			st.Runs++
+			if h.Error != "" {
+				st.Failures++
+				if st.Error == "" {
+					st.Error = h.Error
+				}
+			}
+		}
+		if s.Result.Setup != nil {
+			add(*s.Result.Setup)
+		}
+		for _, run := range s.Runs {
+			for _, h := range run.Hooks {
+				add(h)
+			}
+		}
+		if s.Result.Teardown != nil {
+			add(*s.Result.Teardown)
+		}
+		for _, name := range []string{executor.HookSetup, executor.HookBeforeRun, executor.HookAfterRun, executor.HookTeardown} {
+			if st, ok := byHook[name]; ok {
+				hookStats = append(hookStats, *st)
+			}
+		}
+	}
+	return hookStats
+}
+
+// StrayRuns counts the measured runs that left processes behind once their
+// script had exited.
+func (r Report) StrayRuns() int {
+	n := 0
+	for _, s := range r.ScenarioRuns() {
+		for _, run := range s.Runs {
+			if len(run.Strays) > 0 {
+				n++
+			}
+		}
+	}
+	return n
 }
 
 type Config struct {
-	BaselineScript  string `json:"baseline_script"`
-	OptimizedScript string `json:"optimized_script"`
-	Mode            string `json:"mode"`
-	WarmupRuns      int    `json:"warmup_runs"`
-	MeasuredRuns    int    `json:"measured_runs"`
-	Alternate       bool   `json:"alternate"`
-	CooldownMs      int    `json:"cooldown_ms"`
-	Timeout         int    `json:"timeout"`
+	Suite            string            `json:"suite,omitempty"`
+	SpecFile         string            `json:"spec_file,omitempty"`
+	BaselineScript   string            `json:"baseline_script"`
+	OptimizedScript  string            `json:"optimized_script,omitempty"`
+	Candidates       []Candidate       `json:"candidates,omitempty"`
+	Order            string            `json:"order,omitempty"`
+	Mode             string            `json:"mode"`
+	WarmupRuns       int               `json:"warmup_runs"`
+	VerdictThreshold float64           `json:"verdict_threshold_percent"`
+	Confidence       float64           `json:"confidence_level"`
+	Resamples        int               `json:"bootstrap_resamples"`
+	Seed             int64             `json:"seed"`
+	Alpha            float64           `json:"alpha"`
+	MinEffect        float64           `json:"min_effect"`
+	Adaptive         bool              `json:"adaptive"`
+	MinRuns          int               `json:"min_runs,omitempty"`
+	MaxRuns          int               `json:"max_runs,omitempty"`
+	BatchSize        int               `json:"batch_size,omitempty"`
+	TargetCIWidth    float64           `json:"target_ci_width,omitempty"`
+	BudgetSec        float64           `json:"budget_sec,omitempty"`
+	MeasuredRuns     int               `json:"measured_runs"`
+	Alternate        bool              `json:"alternate"`
+	CooldownMs       int               `json:"cooldown_ms"`
+	Timeout          int               `json:"timeout"`
+	EnvFile          string            `json:"env_file,omitempty"`
+	Env              map[string]string `json:"env,omitempty"`
+	Tags             []string          `json:"tags,omitempty"`
+	Collect          []string          `json:"collect,omitempty"`
+	Collectors       []Collector       `json:"collectors,omitempty"`
+	CgroupMode       string            `json:"cgroup_mode,omitempty"`
+	CPUMax           string            `json:"cpu_max,omitempty"`
+	CPUSetCPUs       string            `json:"cpuset_cpus,omitempty"`
+	MemoryMax        string            `json:"memory_max,omitempty"`
+	CPUs             string            `json:"cpus,omitempty"`
+	SameCores        bool              `json:"same_cores,omitempty"`
+	Nice             int               `json:"nice,omitempty"`
+	IONice           string            `json:"ionice,omitempty"`
+	Artifacts        bool              `json:"artifacts,omitempty"`
+	ArtifactMaxSize  string            `json:"artifact_max_size,omitempty"`
+	ArtifactGzip     bool              `json:"artifact_gzip,omitempty"`
+	// ArtifactDir holds the output files of the runs, relative to the report
+	ArtifactDir    string `json:"artifact_dir,omitempty"`
+	Verify         string `json:"verify,omitempty"`
+	VerifyCommand  string `json:"verify_command,omitempty"`
+	VerifyChecksum bool   `json:"verify_checksum,omitempty"`
+	// Hooks apply to every scenario; ScenarioHooks override them by
+	// scenario name
+	Hooks         executor.Hooks            `json:"hooks"`
+	ScenarioHooks map[string]executor.Hooks `json:"scenario_hooks,omitempty"`
+}
+
+// Stop reasons of the measurement phase.
+const (
+	StopFixedRuns  = "fixed_runs"
+	StopConclusive = "conclusive"
+	StopCIWidth    = "target_ci_width"
+	StopMaxRuns    = "max_runs"
+	StopTimeBudget = "time_budget"
+)
+
+// Sampling records how many runs were measured and why sampling stopped.
+type Sampling struct {
+	Adaptive   bool    `json:"adaptive"`
+	Runs       int     `json:"runs"`
+	Batches    int     `json:"batches"`
+	StopReason string  `json:"stop_reason"`
+	CIWidth    float64 `json:"ci_width"`
+	ElapsedSec float64 `json:"elapsed_sec"`
+	// ExecutionOrder lists the scenario of every measured run, in the order
+	// the runs were executed.
+	ExecutionOrder []string `json:"execution_order,omitempty"`
+}
+
+// Candidate is a named scenario compared against the baseline.
+type Candidate struct {
+	Name   string `json:"name"`
+	Script string `json:"script"`
+}
+
+// Collector is a user-defined collector command.
+type Collector struct {
+	Name    string `json:"name"`
+	Command string `json:"command"`
+}
+
+// CandidateResult is one candidate of an N-way comparison, ranked by its
+// primary gain over the baseline (rank 1 is the best).
+type CandidateResult struct {
+	Rank       int               `json:"rank"`
+	Name       string            `json:"name"`
+	Script     string            `json:"script"`
+	Scenario   ScenarioResult    `json:"scenario"`
+	Comparison stats.Comparison  `json:"comparison"`
+	Metrics    []MetricResult    `json:"metrics,omitempty"`
+	Verdict    Verdict           `json:"verdict"`
+	Ebpf       *ebpf.Comparison  `json:"ebpf_comparison,omitempty"`
+	Sections   []collect.Section `json:"sections,omitempty"`
 }
 
 type ScenarioResult struct {
-	Runs  []executor.RunResult `json:"runs"`
-	Stats stats.Stats          `json:"stats"`
-	Ebpf  []ebpf.Metrics       `json:"ebpf,omitempty"`
+	Runs   []executor.RunResult `json:"runs"`
+	Values []float64            `json:"values"`
+	Stats  stats.Stats          `json:"stats"`
+	Ebpf   []ebpf.Metrics       `json:"ebpf,omitempty"`
+	// Collected holds the values of the other collectors, per run
+	Collected map[string][][]collect.Value `json:"collected,omitempty"`
+	// Setup and Teardown are the scenario's hooks, when set
+	Setup    *executor.HookResult `json:"setup,omitempty"`
+	Teardown *executor.HookResult `json:"teardown,omitempty"`
+}
+
+// MetricResult summarizes one metric (wall time, CPU time, peak RSS, ...) for
+// both scenarios and compares them. Key metrics take part in the verdict.
+type MetricResult struct {
+	Name       string           `json:"name"`
+	Label      string           `json:"label"`
+	Unit       string           `json:"unit"`
+	Key        bool             `json:"key"`
+	Baseline   stats.Stats      `json:"baseline"`
+	Optimized  stats.Stats      `json:"optimized"`
+	Comparison stats.Comparison `json:"comparison"`
+}
+
+// SummaryIndex lists the reports written by one spec file run.
+type SummaryIndex struct {
+	Version     string         `json:"version"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Machine     string         `json:"machine"`
+	SpecFile    string         `json:"spec_file"`
+	Suites      []SuiteSummary `json:"suites"`
+}
+
+type SuiteSummary struct {
+	Name        string  `json:"name"`
+	JSONReport  string  `json:"json_report,omitempty"`
+	HTMLReport  string  `json:"html_report,omitempty"`
+	Metric      string  `json:"metric,omitempty"`
+	GainPercent float64 `json:"gain_percent"`
+	Conclusive  bool    `json:"conclusive"`
+	Verdict     string  `json:"verdict,omitempty"`
+	Error       string  `json:"error,omitempty"`
 }
 
 type AggregateReport struct {
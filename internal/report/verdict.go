@@ -0,0 +1,68 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	OutcomeBetter   = "better"
+	OutcomeWorse    = "worse"
+	OutcomeTradeoff = "tradeoff"
+	OutcomeNeutral  = "neutral"
+)
+
+// DefaultVerdictThreshold is the smallest gain (in %) on a key metric that
+// counts as a real improvement or regression.
+const DefaultVerdictThreshold = 2.0
+
+// Verdict is the Pareto view over all key metrics: the optimized scenario is
+// only "better" if no key metric got conclusively worse.
+type Verdict struct {
+	Outcome   string   `json:"outcome"`
+	Improved  []string `json:"improved,omitempty"`
+	Regressed []string `json:"regressed,omitempty"`
+	Summary   string   `json:"summary"`
+	Threshold float64  `json:"threshold_percent"`
+}
+
+// ParetoVerdict classifies the key metrics into improvements and regressions.
+// Only conclusive comparisons whose gain exceeds threshold are taken into
+// account, so noise on a stable metric does not turn a win into a trade-off.
+func ParetoVerdict(metrics []MetricResult, threshold float64) Verdict {
+	v := Verdict{Threshold: threshold}
+
+	var better, worse []string
+	for _, m := range metrics {
+		if !m.Key || !m.Comparison.Conclusive || math.Abs(m.Comparison.GainPercent) < threshold {
+			continue
+		}
+		desc := fmt.Sprintf("%s %+.1f%%", m.Label, m.Comparison.GainPercent)
+		if m.Comparison.GainPercent > 0 {
+			v.Improved = append(v.Improved, m.Name)
+			better = append(better, desc)
+		} else {
+			v.Regressed = append(v.Regressed, m.Name)
+			worse = append(worse, desc)
+		}
+	}
+
+	switch {
+	case len(better) > 0 && len(worse) > 0:
+		v.Outcome = OutcomeTradeoff
+		v.Summary = fmt.Sprintf("Trade-off: better on %s but worse on %s",
+			strings.Join(better, ", "), strings.Join(worse, ", "))
+	case len(better) > 0:
+		v.Outcome = OutcomeBetter
+		v.Summary = "Better on " + strings.Join(better, ", ") + " with no key metric regressing"
+	case len(worse) > 0:
+		v.Outcome = OutcomeWorse
+		v.Summary = "Worse on " + strings.Join(worse, ", ")
+	default:
+		v.Outcome = OutcomeNeutral
+		v.Summary = fmt.Sprintf("No key metric changed conclusively by more than %.1f%%", threshold)
+	}
+
+	return v
+}
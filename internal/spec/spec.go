@@ -0,0 +1,270 @@
+package spec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is a corecut.yaml benchmark specification: shared defaults and one or
+// more named suites, each comparing a baseline with an optimized scenario.
+type File struct {
+	Defaults Suite   `yaml:"defaults"`
+	Suites   []Suite `yaml:"suites"`
+
+	// Path is the file the spec was loaded from.
+	Path string `yaml:"-"`
+}
+
+// Suite is one named comparison. Pointer fields distinguish "not set" from a
+// zero value so that defaults and CLI flags can be layered on top.
+type Suite struct {
+	Name            string            `yaml:"name"`
+	Baseline        string            `yaml:"baseline"`
+	Optimized       string            `yaml:"optimized"`
+	Candidates      []Candidate       `yaml:"candidates"`
+	Mode            string            `yaml:"mode"`
+	Order           string            `yaml:"order"`
+	Runs            *int              `yaml:"runs"`
+	Warmup          *int              `yaml:"warmup"`
+	Alternate       *bool             `yaml:"alternate"`
+	CooldownMs      *int              `yaml:"cooldown_ms"`
+	Timeout         *int              `yaml:"timeout"`
+	EnvFile         string            `yaml:"env_file"`
+	Env             map[string]string `yaml:"env"`
+	Tags            []string          `yaml:"tags"`
+	Collect         []string          `yaml:"collect"`
+	Collectors      []Collector       `yaml:"collectors"`
+	Cgroup          string            `yaml:"cgroup"`
+	CPUMax          string            `yaml:"cpu_max"`
+	CPUSetCPUs      string            `yaml:"cpuset_cpus"`
+	MemoryMax       string            `yaml:"memory_max"`
+	CPUs            string            `yaml:"cpus"`
+	SameCores       *bool             `yaml:"same_cores"`
+	Nice            *int              `yaml:"nice"`
+	IONice          string            `yaml:"ionice"`
+	Artifacts       *bool             `yaml:"artifacts"`
+	ArtifactMaxSize string            `yaml:"artifact_max_size"`
+	ArtifactGzip    *bool             `yaml:"artifact_gzip"`
+	Verify          string            `yaml:"verify"`
+	VerifyCommand   string            `yaml:"verify_command"`
+	VerifyChecksum  *bool             `yaml:"verify_checksum"`
+	Hooks           Hooks             `yaml:"hooks"`
+	// ScenarioHooks override Hooks for the named scenarios (baseline,
+	// optimized or a candidate)
+	ScenarioHooks map[string]Hooks `yaml:"scenario_hooks"`
+}
+
+// Candidate is an extra named scenario compared against the baseline.
+type Candidate struct {
+	Name   string `yaml:"name"`
+	Script string `yaml:"script"`
+}
+
+// Hooks are commands run around the scenarios (see --setup).
+type Hooks struct {
+	Setup     string `yaml:"setup"`
+	Teardown  string `yaml:"teardown"`
+	BeforeRun string `yaml:"before_run"`
+	AfterRun  string `yaml:"after_run"`
+}
+
+// Collector is a user-defined collector command (see --collector).
+type Collector struct {
+	Name    string `yaml:"name"`
+	Command string `yaml:"command"`
+}
+
+// Load reads and validates a spec file. Relative script and env file paths
+// are resolved against the directory of the spec file.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec: %w", err)
+	}
+
+	// Unknown keys are errors, so that a misspelled one is not silently
+	// replaced by its default
+	var f File
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse spec %s: %w", path, err)
+	}
+	f.Path = path
+
+	if len(f.Suites) == 0 {
+		return nil, fmt.Errorf("spec %s defines no suites", path)
+	}
+
+	// Scripts are resolved to absolute paths: joined to a relative directory,
+	// "./a.sh" would lose its "./" and bash -c would look a.sh up on PATH
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for i := range f.Suites {
+		s := f.Suites[i].withDefaults(f.Defaults)
+		if s.Name == "" {
+			return nil, fmt.Errorf("suite #%d has no name", i+1)
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("duplicate suite name %q", s.Name)
+		}
+		seen[s.Name] = true
+		if s.Baseline == "" || (s.Optimized == "" && len(s.Candidates) == 0) {
+			return nil, fmt.Errorf("suite %q needs a baseline and at least one of optimized or candidates", s.Name)
+		}
+
+		s.Baseline = resolvePath(dir, s.Baseline)
+		if s.Optimized != "" {
+			s.Optimized = resolvePath(dir, s.Optimized)
+		}
+		candidates := make([]Candidate, len(s.Candidates))
+		for j, c := range s.Candidates {
+			if c.Name == "" || c.Script == "" {
+				return nil, fmt.Errorf("suite %q: candidate #%d needs a name and a script", s.Name, j+1)
+			}
+			candidates[j] = Candidate{Name: c.Name, Script: resolvePath(dir, c.Script)}
+		}
+		s.Candidates = candidates
+		for j, c := range s.Collectors {
+			if c.Name == "" || c.Command == "" {
+				return nil, fmt.Errorf("suite %q: collector #%d needs a name and a command", s.Name, j+1)
+			}
+		}
+		if s.EnvFile != "" {
+			s.EnvFile = resolvePath(dir, s.EnvFile)
+		}
+		f.Suites[i] = s
+	}
+
+	return &f, nil
+}
+
+// withDefaults fills every field the suite leaves unset from d. Env maps are
+// merged, with the suite's values winning.
+func (s Suite) withDefaults(d Suite) Suite {
+	if s.Baseline == "" {
+		s.Baseline = d.Baseline
+	}
+	if s.Optimized == "" && len(s.Candidates) == 0 {
+		s.Optimized = d.Optimized
+		s.Candidates = d.Candidates
+	}
+	if s.Mode == "" {
+		s.Mode = d.Mode
+	}
+	if s.Order == "" {
+		s.Order = d.Order
+	}
+	if s.Runs == nil {
+		s.Runs = d.Runs
+	}
+	if s.Warmup == nil {
+		s.Warmup = d.Warmup
+	}
+	if s.Alternate == nil {
+		s.Alternate = d.Alternate
+	}
+	if s.CooldownMs == nil {
+		s.CooldownMs = d.CooldownMs
+	}
+	if s.Timeout == nil {
+		s.Timeout = d.Timeout
+	}
+	if s.EnvFile == "" {
+		s.EnvFile = d.EnvFile
+	}
+	if len(s.Tags) == 0 {
+		s.Tags = d.Tags
+	}
+	if len(s.Collect) == 0 {
+		s.Collect = d.Collect
+	}
+	if len(s.Collectors) == 0 {
+		s.Collectors = d.Collectors
+	}
+	if s.Cgroup == "" {
+		s.Cgroup = d.Cgroup
+	}
+	if s.CPUMax == "" {
+		s.CPUMax = d.CPUMax
+	}
+	if s.CPUSetCPUs == "" {
+		s.CPUSetCPUs = d.CPUSetCPUs
+	}
+	if s.MemoryMax == "" {
+		s.MemoryMax = d.MemoryMax
+	}
+	if s.CPUs == "" {
+		s.CPUs = d.CPUs
+	}
+	if s.SameCores == nil {
+		s.SameCores = d.SameCores
+	}
+	if s.Nice == nil {
+		s.Nice = d.Nice
+	}
+	if s.IONice == "" {
+		s.IONice = d.IONice
+	}
+	if s.Artifacts == nil {
+		s.Artifacts = d.Artifacts
+	}
+	if s.ArtifactMaxSize == "" {
+		s.ArtifactMaxSize = d.ArtifactMaxSize
+	}
+	if s.ArtifactGzip == nil {
+		s.ArtifactGzip = d.ArtifactGzip
+	}
+	if s.Verify == "" {
+		s.Verify = d.Verify
+	}
+	if s.VerifyCommand == "" {
+		s.VerifyCommand = d.VerifyCommand
+	}
+	if s.VerifyChecksum == nil {
+		s.VerifyChecksum = d.VerifyChecksum
+	}
+	if s.Hooks.Setup == "" {
+		s.Hooks.Setup = d.Hooks.Setup
+	}
+	if s.Hooks.Teardown == "" {
+		s.Hooks.Teardown = d.Hooks.Teardown
+	}
+	if s.Hooks.BeforeRun == "" {
+		s.Hooks.BeforeRun = d.Hooks.BeforeRun
+	}
+	if s.Hooks.AfterRun == "" {
+		s.Hooks.AfterRun = d.Hooks.AfterRun
+	}
+	if len(s.ScenarioHooks) == 0 {
+		s.ScenarioHooks = d.ScenarioHooks
+	}
+
+	if len(d.Env) > 0 {
+		env := make(map[string]string, len(d.Env)+len(s.Env))
+		for k, v := range d.Env {
+			env[k] = v
+		}
+		for k, v := range s.Env {
+			env[k] = v
+		}
+		s.Env = env
+	}
+
+	return s
+}
+
+func resolvePath(dir, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
@@ -0,0 +1,53 @@
+package spec
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Scripts given as ./script in a spec loaded through a relative path must
+// still run: bash -c "a.sh" would look a.sh up on PATH.
+func TestLoadRelativeScripts(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "bench")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/bash\necho ran\n"
+	if err := os.WriteFile(filepath.Join(dir, "a.sh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	spec := "suites:\n  - name: s\n    baseline: ./a.sh\n    optimized: ./a.sh\n"
+	if err := os.WriteFile(filepath.Join(dir, "corecut.yaml"), []byte(spec), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	for _, tt := range []struct{ cwd, path string }{
+		{cwd: dir, path: "corecut.yaml"},
+		{cwd: dir, path: "./corecut.yaml"},
+		{cwd: root, path: "bench/corecut.yaml"},
+	} {
+		t.Run(tt.path, func(t *testing.T) {
+			if err := os.Chdir(tt.cwd); err != nil {
+				t.Fatal(err)
+			}
+			f, err := Load(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := exec.Command("/bin/bash", "-c", f.Suites[0].Baseline).CombinedOutput()
+			if err != nil || strings.TrimSpace(string(out)) != "ran" {
+				t.Errorf("bash -c %s = %q, %v, want the script to run", f.Suites[0].Baseline, out, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	DefaultConfidence = 0.95
+	DefaultResamples  = 2000
+)
+
+const (
+	CIMethodBCa        = "bca"
+	CIMethodPercentile = "percentile"
+)
+
+// bootstrapResult holds the confidence interval of the median-ratio gain and
+// the sorted bootstrap distribution it was read from.
+type bootstrapResult struct {
+	Low    float64
+	High   float64
+	Method string
+	Dist   []float64
+}
+
+// bootstrapGain resamples both scenarios (jointly per pair when paired) and
+// returns a BCa confidence interval for the gain, falling back to the
+// percentile method when the bias correction is undefined.
+func bootstrapGain(baseline, optimized []float64, paired bool, opts Options) bootstrapResult {
+	rng := rand.New(rand.NewSource(opts.Seed))
+	observed := medianGain(baseline, optimized, opts.Direction)
+
+	dist := make([]float64, opts.Resamples)
+	bSample := make([]float64, len(baseline))
+	oSample := make([]float64, len(optimized))
+	for b := range dist {
+		if paired {
+			for i := range bSample {
+				j := rng.Intn(len(baseline))
+				bSample[i] = baseline[j]
+				oSample[i] = optimized[j]
+			}
+		} else {
+			for i := range bSample {
+				bSample[i] = baseline[rng.Intn(len(baseline))]
+			}
+			for i := range oSample {
+				oSample[i] = optimized[rng.Intn(len(optimized))]
+			}
+		}
+		dist[b] = medianGain(bSample, oSample, opts.Direction)
+	}
+	sort.Float64s(dist)
+
+	alpha := 1 - opts.Confidence
+	res := bootstrapResult{
+		Low:    percentile(dist, alpha/2*100),
+		High:   percentile(dist, (1-alpha/2)*100),
+		Method: CIMethodPercentile,
+		Dist:   dist,
+	}
+
+	// Bias correction: share of resamples below the observed gain
+	below := 0.0
+	for _, g := range dist {
+		if g < observed {
+			below++
+		} else if g == observed {
+			below += 0.5
+		}
+	}
+	z0 := normQuantile(below / float64(len(dist)))
+	if math.IsInf(z0, 0) || math.IsNaN(z0) {
+		return res
+	}
+
+	a := acceleration(baseline, optimized, paired, opts.Direction)
+	adjust := func(p float64) float64 {
+		z := normQuantile(p)
+		return normCDF(z0 + (z0+z)/(1-a*(z0+z)))
+	}
+	lowP, highP := adjust(alpha/2), adjust(1-alpha/2)
+	if math.IsNaN(lowP) || math.IsNaN(highP) {
+		return res
+	}
+
+	res.Low = percentile(dist, lowP*100)
+	res.High = percentile(dist, highP*100)
+	res.Method = CIMethodBCa
+	return res
+}
+
+// acceleration estimates the BCa acceleration constant with a jackknife:
+// leave out one pair (paired) or one observation from either sample.
+func acceleration(baseline, optimized []float64, paired bool, dir Direction) float64 {
+	var jack []float64
+	if paired {
+		for i := range baseline {
+			jack = append(jack, medianGain(without(baseline, i), without(optimized, i), dir))
+		}
+	} else {
+		for i := range baseline {
+			if len(baseline) > 1 {
+				jack = append(jack, medianGain(without(baseline, i), optimized, dir))
+			}
+		}
+		for i := range optimized {
+			if len(optimized) > 1 {
+				jack = append(jack, medianGain(baseline, without(optimized, i), dir))
+			}
+		}
+	}
+	if len(jack) < 2 {
+		return 0
+	}
+
+	mean := 0.0
+	for _, v := range jack {
+		mean += v
+	}
+	mean /= float64(len(jack))
+
+	var num, den float64
+	for _, v := range jack {
+		d := mean - v
+		num += d * d * d
+		den += d * d
+	}
+	if den == 0 {
+		return 0
+	}
+	return num / (6 * math.Pow(den, 1.5))
+}
+
+func medianGain(baseline, optimized []float64, dir Direction) float64 {
+	return Gain(median(baseline), median(optimized), dir)
+}
+
+func median(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	return percentile(sorted, 50)
+}
+
+func without(values []float64, i int) []float64 {
+	out := make([]float64, 0, len(values)-1)
+	out = append(out, values[:i]...)
+	return append(out, values[i+1:]...)
+}
+
+func normCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+// normQuantile is the inverse of the standard normal CDF (Acklam's rational
+// approximation, relative error below 1.2e-9).
+func normQuantile(p float64) float64 {
+	if p <= 0 {
+		return math.Inf(-1)
+	}
+	if p >= 1 {
+		return math.Inf(1)
+	}
+
+	a := [6]float64{-3.969683028665376e+01, 2.209460984245205e+02, -2.759285104469687e+02,
+		1.383577518672690e+02, -3.066479806614716e+01, 2.506628277459239e+00}
+	b := [5]float64{-5.447609879822406e+01, 1.615858368580409e+02, -1.556989798598866e+02,
+		6.680131188771972e+01, -1.328068155288572e+01}
+	c := [6]float64{-7.784894002430293e-03, -3.223964580411365e-01, -2.400758277161838e+00,
+		-2.549732539343734e+00, 4.374664141464968e+00, 2.938163982698783e+00}
+	d := [4]float64{7.784695709041462e-03, 3.224671290700398e-01, 2.445134137142996e+00,
+		3.754408661907416e+00}
+
+	const pLow = 0.02425
+	switch {
+	case p < pLow:
+		q := math.Sqrt(-2 * math.Log(p))
+		return (((((c[0]*q+c[1])*q+c[2])*q+c[3])*q+c[4])*q + c[5]) /
+			((((d[0]*q+d[1])*q+d[2])*q+d[3])*q + 1)
+	case p > 1-pLow:
+		q := math.Sqrt(-2 * math.Log(1-p))
+		return -(((((c[0]*q+c[1])*q+c[2])*q+c[3])*q+c[4])*q + c[5]) /
+			((((d[0]*q+d[1])*q+d[2])*q+d[3])*q + 1)
+	default:
+		q := p - 0.5
+		r := q * q
+		return (((((a[0]*r+a[1])*r+a[2])*r+a[3])*r+a[4])*r + a[5]) * q /
+			(((((b[0]*r+b[1])*r+b[2])*r+b[3])*r+b[4])*r + 1)
+	}
+}
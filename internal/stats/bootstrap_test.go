@@ -0,0 +1,102 @@
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+// Reference values are those of R's qnorm and pnorm.
+func TestNormQuantile(t *testing.T) {
+	tests := []struct {
+		p, want float64
+	}{
+		{0.5, 0},
+		{0.975, 1.9599639845400536},
+		{0.01, -2.3263478740408408},
+		{0.999, 3.090232306167813},
+	}
+	for _, tt := range tests {
+		got := normQuantile(tt.p)
+		if !near(got, tt.want, 1e-8) {
+			t.Errorf("normQuantile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+		if back := normCDF(got); !near(back, tt.p, 1e-9) {
+			t.Errorf("normCDF(normQuantile(%v)) = %v", tt.p, back)
+		}
+	}
+	if !math.IsInf(normQuantile(0), -1) || !math.IsInf(normQuantile(1), 1) {
+		t.Errorf("normQuantile(0), normQuantile(1) = %v, %v, want -Inf, +Inf", normQuantile(0), normQuantile(1))
+	}
+}
+
+// The acceleration is the jackknife skewness of the median gain, as in
+// bcanon of R's bootstrap package.
+func TestAcceleration(t *testing.T) {
+	tests := []struct {
+		name                string
+		baseline, optimized []float64
+		paired              bool
+		want                float64
+	}{
+		{name: "unpaired", baseline: []float64{10, 12, 11, 13, 15}, optimized: []float64{8, 9, 9, 10, 12}, want: -0.020867472789656574},
+		{name: "paired", baseline: []float64{10, 12, 11, 13, 15}, optimized: []float64{8, 9, 9, 10, 12}, paired: true, want: -0.014933199899347575},
+		{name: "skewed unpaired", baseline: []float64{10, 11, 12, 14, 20}, optimized: []float64{9, 9, 10, 13, 14}, want: 0.019751320479559503},
+		{name: "skewed paired", baseline: []float64{10, 11, 12, 14, 20}, optimized: []float64{9, 9, 10, 13, 14}, paired: true, want: -0.029616724534058985},
+		{name: "n=1", baseline: []float64{10}, optimized: []float64{5}, want: 0},
+		{name: "all equal", baseline: []float64{5, 5, 5}, optimized: []float64{5, 5, 5}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := acceleration(tt.baseline, tt.optimized, tt.paired, LowerIsBetter); !near(got, tt.want, 1e-12) {
+				t.Errorf("acceleration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBootstrapGain(t *testing.T) {
+	opts := Options{Seed: 1}.withDefaults()
+	tests := []struct {
+		name                string
+		baseline, optimized []float64
+		paired              bool
+		low, high           float64
+		method              string
+	}{
+		{name: "n=1", baseline: []float64{10}, optimized: []float64{5}, low: 50, high: 50, method: CIMethodBCa},
+		{name: "all equal", baseline: []float64{5, 5, 5}, optimized: []float64{5, 5, 5}, low: 0, high: 0, method: CIMethodBCa},
+		{
+			// Every pair halves the time: any resample of whole pairs gains
+			// exactly 50%
+			name:      "aligned pairs",
+			baseline:  []float64{10, 40, 20, 80},
+			optimized: []float64{5, 20, 10, 40},
+			paired:    true, low: 50, high: 50, method: CIMethodBCa,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := bootstrapGain(tt.baseline, tt.optimized, tt.paired, opts)
+			if !near(got.Low, tt.low, 1e-9) || !near(got.High, tt.high, 1e-9) || got.Method != tt.method {
+				t.Errorf("bootstrapGain() = [%v, %v] %s, want [%v, %v] %s", got.Low, got.High, got.Method, tt.low, tt.high, tt.method)
+			}
+		})
+	}
+}
+
+func TestBootstrapGainContainsObserved(t *testing.T) {
+	baseline := []float64{102, 98, 110, 95, 104, 99, 120, 101, 97, 105}
+	optimized := []float64{81, 85, 79, 90, 84, 83, 95, 80, 86, 82}
+	for _, paired := range []bool{false, true} {
+		opts := Options{Seed: 7}.withDefaults()
+		a := bootstrapGain(baseline, optimized, paired, opts)
+		b := bootstrapGain(baseline, optimized, paired, opts)
+		if a.Low != b.Low || a.High != b.High {
+			t.Errorf("paired=%v: not deterministic for a seed: [%v, %v] then [%v, %v]", paired, a.Low, a.High, b.Low, b.High)
+		}
+		observed := medianGain(baseline, optimized, LowerIsBetter)
+		if a.Low > observed || a.High < observed || a.Low <= 0 {
+			t.Errorf("paired=%v: CI [%v, %v] should hold the observed gain %v and exclude 0", paired, a.Low, a.High, observed)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package stats
+
+import "sort"
+
+// CorrectionHolm names the Holm-Bonferroni step-down correction.
+const CorrectionHolm = "holm-bonferroni"
+
+// HolmAdjust returns Holm-Bonferroni adjusted p-values, in the input order.
+// It controls the family-wise error rate without assuming independence.
+func HolmAdjust(pvalues []float64) []float64 {
+	m := len(pvalues)
+	order := make([]int, m)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return pvalues[order[a]] < pvalues[order[b]] })
+
+	adjusted := make([]float64, m)
+	running := 0.0
+	for k, i := range order {
+		p := float64(m-k) * pvalues[i]
+		if p > 1 {
+			p = 1
+		}
+		// Adjusted p-values must not decrease along the sorted order
+		if p < running {
+			p = running
+		}
+		running = p
+		adjusted[i] = p
+	}
+	return adjusted
+}
+
+// AdjustMultiple corrects comparisons made against one shared baseline for
+// multiple testing and re-evaluates their conclusiveness on the adjusted
+// p-values. A single comparison is left untouched.
+func AdjustMultiple(comps []Comparison, opts Options) {
+	if len(comps) < 2 {
+		return
+	}
+	opts = opts.withDefaults()
+
+	pvalues := make([]float64, len(comps))
+	for i, c := range comps {
+		pvalues[i] = c.PValue
+	}
+	for i, p := range HolmAdjust(pvalues) {
+		comps[i].AdjustedP = p
+		comps[i].Correction = CorrectionHolm
+		comps[i].Conclusive = comps[i].conclusive(p, opts)
+	}
+}
@@ -0,0 +1,31 @@
+package stats
+
+import "testing"
+
+// Reference values are those of R's p.adjust(p, method = "holm").
+func TestHolmAdjust(t *testing.T) {
+	tests := []struct {
+		name string
+		p    []float64
+		want []float64
+	}{
+		{name: "monotone", p: []float64{0.01, 0.04, 0.03, 0.005}, want: []float64{0.03, 0.06, 0.06, 0.02}},
+		{name: "capped", p: []float64{0.5, 0.2, 0.01}, want: []float64{0.5, 0.4, 0.03}},
+		{name: "ties", p: []float64{0.02, 0.02}, want: []float64{0.04, 0.04}},
+		{name: "single", p: []float64{0.3}, want: []float64{0.3}},
+		{name: "empty", p: nil, want: []float64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := HolmAdjust(tt.p)
+			if len(got) != len(tt.want) {
+				t.Fatalf("HolmAdjust() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !near(got[i], tt.want[i], 1e-12) {
+					t.Fatalf("HolmAdjust() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
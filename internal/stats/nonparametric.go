@@ -0,0 +1,241 @@
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	DefaultAlpha = 0.05
+	// DefaultMinEffect is the |Cliff's delta| below which a difference is
+	// considered negligible (Romano et al.).
+	DefaultMinEffect = 0.147
+)
+
+const (
+	TestMannWhitney = "mann-whitney"
+	TestWilcoxon    = "wilcoxon-signed-rank"
+)
+
+// exactLimit bounds the sample size for which exact null distributions are
+// enumerated; larger samples use the normal approximation.
+const exactLimit = 30
+
+// MannWhitney runs a two-sided Mann-Whitney U test of baseline vs optimized.
+// It returns the U statistic of the optimized sample and the p-value.
+func MannWhitney(baseline, optimized []float64) (u, p float64) {
+	m, n := len(optimized), len(baseline)
+	if m == 0 || n == 0 {
+		return 0, 1
+	}
+
+	all := append(append([]float64{}, optimized...), baseline...)
+	ranks, ties := rank(all)
+
+	rankSum := 0.0
+	for i := 0; i < m; i++ {
+		rankSum += ranks[i]
+	}
+	u = rankSum - float64(m*(m+1))/2
+
+	if len(ties) == 0 && m <= exactLimit && n <= exactLimit {
+		return u, exactTwoSided(mannWhitneyCounts(m, n), u)
+	}
+
+	mn := float64(m * n)
+	total := float64(m + n)
+	tieTerm := 0.0
+	for _, t := range ties {
+		tieTerm += float64(t*t*t - t)
+	}
+	variance := mn / 12 * ((total + 1) - tieTerm/(total*(total-1)))
+	return u, normalTwoSided(u, mn/2, variance)
+}
+
+// Wilcoxon runs a two-sided Wilcoxon signed-rank test on the paired
+// differences optimized[i] - baseline[i]. Zero differences are dropped.
+// It returns the W+ statistic and the p-value.
+func Wilcoxon(baseline, optimized []float64) (w, p float64) {
+	var diffs []float64
+	for i := range baseline {
+		if i >= len(optimized) {
+			break
+		}
+		if d := optimized[i] - baseline[i]; d != 0 {
+			diffs = append(diffs, d)
+		}
+	}
+	n := len(diffs)
+	if n == 0 {
+		return 0, 1
+	}
+
+	abs := make([]float64, n)
+	for i, d := range diffs {
+		abs[i] = math.Abs(d)
+	}
+	ranks, ties := rank(abs)
+	for i, d := range diffs {
+		if d > 0 {
+			w += ranks[i]
+		}
+	}
+
+	if len(ties) == 0 && n <= exactLimit {
+		return w, exactTwoSided(signedRankCounts(n), w)
+	}
+
+	nf := float64(n)
+	tieTerm := 0.0
+	for _, t := range ties {
+		tieTerm += float64(t*t*t - t)
+	}
+	variance := nf*(nf+1)*(2*nf+1)/24 - tieTerm/48
+	return w, normalTwoSided(w, nf*(nf+1)/4, variance)
+}
+
+// CliffsDelta is P(optimized > baseline) - P(optimized < baseline), in [-1, 1].
+func CliffsDelta(baseline, optimized []float64) float64 {
+	if len(baseline) == 0 || len(optimized) == 0 {
+		return 0
+	}
+	var greater, less int
+	for _, o := range optimized {
+		for _, b := range baseline {
+			if o > b {
+				greater++
+			} else if o < b {
+				less++
+			}
+		}
+	}
+	return float64(greater-less) / float64(len(baseline)*len(optimized))
+}
+
+// HodgesLehmann estimates the location shift optimized - baseline in the
+// metric's unit: the median of all pairwise differences, or of the Walsh
+// averages of the paired differences when paired.
+func HodgesLehmann(baseline, optimized []float64, paired bool) float64 {
+	var est []float64
+	if paired {
+		n := len(baseline)
+		if len(optimized) < n {
+			n = len(optimized)
+		}
+		for i := 0; i < n; i++ {
+			di := optimized[i] - baseline[i]
+			for j := i; j < n; j++ {
+				dj := optimized[j] - baseline[j]
+				est = append(est, (di+dj)/2)
+			}
+		}
+	} else {
+		for _, o := range optimized {
+			for _, b := range baseline {
+				est = append(est, o-b)
+			}
+		}
+	}
+	if len(est) == 0 {
+		return 0
+	}
+	sort.Float64s(est)
+	return percentile(est, 50)
+}
+
+// rank assigns average ranks (1-based) and returns the sizes of tie groups.
+func rank(values []float64) ([]float64, []int) {
+	idx := make([]int, len(values))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(a, b int) bool { return values[idx[a]] < values[idx[b]] })
+
+	ranks := make([]float64, len(values))
+	var ties []int
+	for i := 0; i < len(idx); {
+		j := i
+		for j+1 < len(idx) && values[idx[j+1]] == values[idx[i]] {
+			j++
+		}
+		avg := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			ranks[idx[k]] = avg
+		}
+		if j > i {
+			ties = append(ties, j-i+1)
+		}
+		i = j + 1
+	}
+	return ranks, ties
+}
+
+// mannWhitneyCounts returns, for each U in [0, m*n], the number of orderings
+// of m optimized and n baseline values giving that U.
+func mannWhitneyCounts(m, n int) []float64 {
+	// f[i][j] is the distribution for sizes (i, j); f(i,j)[u] =
+	// f(i-1,j)[u-j] + f(i,j-1)[u].
+	prev := make([][]float64, n+1)
+	for j := 0; j <= n; j++ {
+		prev[j] = []float64{1}
+	}
+	for i := 1; i <= m; i++ {
+		cur := make([][]float64, n+1)
+		cur[0] = []float64{1}
+		for j := 1; j <= n; j++ {
+			dist := make([]float64, i*j+1)
+			for u, c := range prev[j] {
+				dist[u+j] += c
+			}
+			for u, c := range cur[j-1] {
+				dist[u] += c
+			}
+			cur[j] = dist
+		}
+		prev = cur
+	}
+	return prev[n]
+}
+
+// signedRankCounts returns, for each W+ in [0, n(n+1)/2], the number of sign
+// assignments of ranks 1..n giving that W+.
+func signedRankCounts(n int) []float64 {
+	max := n * (n + 1) / 2
+	dist := make([]float64, max+1)
+	dist[0] = 1
+	for r := 1; r <= n; r++ {
+		for s := max; s >= r; s-- {
+			dist[s] += dist[s-r]
+		}
+	}
+	return dist
+}
+
+func exactTwoSided(counts []float64, stat float64) float64 {
+	total := 0.0
+	for _, c := range counts {
+		total += c
+	}
+	var lower, upper float64
+	for v, c := range counts {
+		if float64(v) <= stat {
+			lower += c
+		}
+		if float64(v) >= stat {
+			upper += c
+		}
+	}
+	return math.Min(1, 2*math.Min(lower, upper)/total)
+}
+
+func normalTwoSided(stat, mean, variance float64) float64 {
+	if variance <= 0 {
+		return 1
+	}
+	// Continuity correction
+	z := (math.Abs(stat-mean) - 0.5) / math.Sqrt(variance)
+	if z < 0 {
+		return 1
+	}
+	return math.Erfc(z / math.Sqrt2)
+}
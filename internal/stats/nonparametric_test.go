@@ -0,0 +1,129 @@
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+// Reference values are those of R's wilcox.test (exact for small samples
+// without ties, normal approximation with continuity and tie correction
+// otherwise); R's W is our U of the first sample. R has no p-value for
+// all-equal samples, where we report 1.
+func TestMannWhitneyU(t *testing.T) {
+	tests := []struct {
+		name                string
+		baseline, optimized []float64
+		u, p                float64
+	}{
+		{
+			// wilcox.test(x, y) from R's documentation: W = 35
+			name:      "exact",
+			baseline:  []float64{1.15, 0.88, 0.90, 0.74, 1.21},
+			optimized: []float64{0.80, 0.83, 1.89, 1.04, 1.45, 1.38, 1.91, 1.64, 0.73, 1.46},
+			u:         35, p: 0.2544122544122544,
+		},
+		{
+			name:      "ties",
+			baseline:  []float64{1, 2, 2, 3, 4},
+			optimized: []float64{2, 3, 5, 6, 6},
+			u:         20.5, p: 0.11049202405566794,
+		},
+		{name: "n=1", baseline: []float64{1}, optimized: []float64{2}, u: 1, p: 1},
+		{name: "n=2", baseline: []float64{1, 2}, optimized: []float64{3, 4}, u: 4, p: 1.0 / 3},
+		{name: "all equal", baseline: []float64{5, 5, 5}, optimized: []float64{5, 5, 5}, u: 4.5, p: 1},
+		{name: "empty", baseline: nil, optimized: []float64{1}, u: 0, p: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, p := MannWhitney(tt.baseline, tt.optimized)
+			if u != tt.u || !near(p, tt.p, 1e-9) {
+				t.Errorf("MannWhitney() = %v, %v, want %v, %v", u, p, tt.u, tt.p)
+			}
+		})
+	}
+}
+
+func TestWilcoxonSignedRank(t *testing.T) {
+	tests := []struct {
+		name                string
+		baseline, optimized []float64
+		w, p                float64
+	}{
+		{
+			// wilcox.test(x, y, paired = TRUE) from R's documentation: V = 40
+			name:      "exact",
+			baseline:  []float64{0.878, 0.647, 0.598, 2.05, 1.06, 1.29, 1.06, 3.14, 1.29},
+			optimized: []float64{1.83, 0.50, 1.62, 2.48, 1.68, 1.88, 1.55, 3.06, 1.30},
+			w:         40, p: 0.0390625,
+		},
+		{
+			// One zero difference is dropped, the others tie
+			name:      "ties and zero",
+			baseline:  []float64{1, 3, 3, 5, 9, 7, 6},
+			optimized: []float64{3, 5, 5, 8, 9, 11, 4},
+			w:         18.5, p: 0.10577220785075259,
+		},
+		{name: "n=1", baseline: []float64{1}, optimized: []float64{2}, w: 1, p: 1},
+		{name: "n=2", baseline: []float64{1, 2}, optimized: []float64{2, 4}, w: 3, p: 0.5},
+		{name: "all equal", baseline: []float64{5, 5, 5}, optimized: []float64{5, 5, 5}, w: 0, p: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, p := Wilcoxon(tt.baseline, tt.optimized)
+			if w != tt.w || !near(p, tt.p, 1e-9) {
+				t.Errorf("Wilcoxon() = %v, %v, want %v, %v", w, p, tt.w, tt.p)
+			}
+		})
+	}
+}
+
+func TestCliffsDelta(t *testing.T) {
+	tests := []struct {
+		name                string
+		baseline, optimized []float64
+		want                float64
+	}{
+		{name: "overlap", baseline: []float64{1, 2, 3}, optimized: []float64{2, 3, 4}, want: 5.0 / 9},
+		{name: "all lower", baseline: []float64{5, 6}, optimized: []float64{1, 2, 3}, want: -1},
+		{name: "n=1", baseline: []float64{1}, optimized: []float64{2}, want: 1},
+		{name: "all equal", baseline: []float64{5, 5}, optimized: []float64{5, 5, 5}, want: 0},
+		{name: "empty", baseline: nil, optimized: []float64{1}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CliffsDelta(tt.baseline, tt.optimized); !near(got, tt.want, 1e-12) {
+				t.Errorf("CliffsDelta() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// The estimates match the "difference in location" of R's
+// wilcox.test(optimized, baseline, conf.int = TRUE).
+func TestHodgesLehmann(t *testing.T) {
+	tests := []struct {
+		name                string
+		baseline, optimized []float64
+		paired              bool
+		want                float64
+	}{
+		{name: "unpaired", baseline: []float64{1, 2, 3}, optimized: []float64{2, 3, 4}, want: 1},
+		{name: "unpaired even", baseline: []float64{1, 3}, optimized: []float64{2, 6}, want: 2},
+		// Walsh averages of the differences 1, 2, 6: 1, 1.5, 3.5, 2, 4, 6
+		{name: "paired", baseline: []float64{1, 2, 3}, optimized: []float64{2, 4, 9}, paired: true, want: 2.75},
+		{name: "n=1", baseline: []float64{10}, optimized: []float64{7}, paired: true, want: -3},
+		{name: "all equal", baseline: []float64{5, 5}, optimized: []float64{5, 5}, want: 0},
+		{name: "empty", baseline: nil, optimized: nil, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HodgesLehmann(tt.baseline, tt.optimized, tt.paired); !near(got, tt.want, 1e-12) {
+				t.Errorf("HodgesLehmann() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func near(got, want, tol float64) bool {
+	return math.Abs(got-want) <= tol
+}
@@ -19,12 +19,68 @@ type Stats struct {
 	P99    float64 `json:"p99"`
 }
 
+// Direction tells Compare which way a metric improves.
+type Direction string
+
+const (
+	LowerIsBetter  Direction = "lower"
+	HigherIsBetter Direction = "higher"
+)
+
+// Options controls how Compare interprets the two samples.
+type Options struct {
+	// Alternate pairs the samples by index: baseline[i] and optimized[i]
+	// must come from the same round. Samples of different lengths are
+	// compared unpaired.
+	Alternate bool
+	Direction Direction
+	// Confidence is the level of the bootstrap gain CI (e.g. 0.95).
+	Confidence float64
+	// Resamples is the number of bootstrap resamples.
+	Resamples int
+	// Seed makes the bootstrap deterministic.
+	Seed int64
+	// Alpha is the significance level of the rank test.
+	Alpha float64
+	// MinEffect is the smallest |Cliff's delta| that counts as a real effect.
+	MinEffect float64
+}
+
 type Comparison struct {
-	GainPercent float64 `json:"gain_percent"`
-	GainP10     float64 `json:"gain_p10"`
-	GainP90     float64 `json:"gain_p90"`
-	Conclusive  bool    `json:"conclusive"`
-	Overlap     float64 `json:"overlap"`
+	Direction   Direction `json:"direction"`
+	GainPercent float64   `json:"gain_percent"`
+	GainP10     float64   `json:"gain_p10"`
+	GainP90     float64   `json:"gain_p90"`
+	GainCILow   float64   `json:"gain_ci_low"`
+	GainCIHigh  float64   `json:"gain_ci_high"`
+	Confidence  float64   `json:"confidence_level"`
+	CIMethod    string    `json:"ci_method,omitempty"`
+	// Test is the rank test the verdict is based on; PValue is its p-value.
+	Test          string  `json:"test,omitempty"`
+	PValue        float64 `json:"p_value"`
+	MannWhitneyU  float64 `json:"mann_whitney_u"`
+	MannWhitneyP  float64 `json:"mann_whitney_p"`
+	WilcoxonW     float64 `json:"wilcoxon_w,omitempty"`
+	WilcoxonP     float64 `json:"wilcoxon_p,omitempty"`
+	CliffsDelta   float64 `json:"cliffs_delta"`
+	HodgesLehmann float64 `json:"hodges_lehmann_shift"`
+	// AdjustedP is PValue after multiple-comparison correction, set when
+	// several candidates are compared against the same baseline.
+	AdjustedP  float64 `json:"adjusted_p_value,omitempty"`
+	Correction string  `json:"correction,omitempty"`
+	Conclusive bool    `json:"conclusive"`
+	Overlap    float64 `json:"overlap"`
+	// Missing is set when a scenario has no sample: nothing was compared.
+	Missing bool `json:"missing,omitempty"`
+	// GainUndefined is set when a baseline sample is zero or negative: no
+	// percentage of it means anything, so the gain, its CI and P10/P90 are
+	// left at 0 (the tests and effect sizes are still computed).
+	GainUndefined bool `json:"gain_undefined,omitempty"`
+}
+
+// HasGain reports whether the comparison has a gain to show.
+func (c Comparison) HasGain() bool {
+	return !c.Missing && !c.GainUndefined
 }
 
 func Calculate(values []float64) Stats {
@@ -71,51 +127,111 @@ func Calculate(values []float64) Stats {
 	return stats
 }
 
-func Compare(baseline, optimized []float64, alternate bool) Comparison {
-	if len(baseline) == 0 || len(optimized) == 0 {
-		return Comparison{}
+// withDefaults fills the unset options with the package defaults.
+func (o Options) withDefaults() Options {
+	if o.Direction == "" {
+		o.Direction = LowerIsBetter
 	}
+	if o.Confidence <= 0 || o.Confidence >= 1 {
+		o.Confidence = DefaultConfidence
+	}
+	if o.Resamples <= 0 {
+		o.Resamples = DefaultResamples
+	}
+	if o.Alpha <= 0 || o.Alpha >= 1 {
+		o.Alpha = DefaultAlpha
+	}
+	if o.MinEffect <= 0 {
+		o.MinEffect = DefaultMinEffect
+	}
+	return o
+}
 
-	baselineStats := Calculate(baseline)
-	optimizedStats := Calculate(optimized)
-
-	// Main gain calculation using medians (robust)
-	gainPercent := 0.0
-	if baselineStats.Median > 0 {
-		gainPercent = ((baselineStats.Median - optimizedStats.Median) / baselineStats.Median) * 100
+func Compare(baseline, optimized []float64, opts Options) Comparison {
+	opts = opts.withDefaults()
+	if len(baseline) == 0 || len(optimized) == 0 {
+		return Comparison{Direction: opts.Direction, PValue: 1, Missing: true}
 	}
 
 	comp := Comparison{
-		GainPercent: gainPercent,
+		Direction:  opts.Direction,
+		Confidence: opts.Confidence,
 	}
+	paired := opts.Alternate && len(baseline) == len(optimized)
+	// Resampled medians of a baseline with a sample <= 0 could be <= 0 too:
+	// there is no gain to estimate rather than one mixing undefined values
+	if Calculate(baseline).Min <= 0 {
+		comp.GainUndefined = true
+		comp.compareRanks(baseline, optimized, paired, opts)
+		return comp
+	}
+
+	// Main gain calculation using medians (robust)
+	comp.GainPercent = medianGain(baseline, optimized, opts.Direction)
+
+	boot := bootstrapGain(baseline, optimized, paired, opts)
+	comp.GainCILow = boot.Low
+	comp.GainCIHigh = boot.High
+	comp.CIMethod = boot.Method
 
 	// Calculate pairwise gains if alternating (more accurate P10/P90)
-	if alternate && len(baseline) == len(optimized) {
+	if paired {
 		pairwiseGains := make([]float64, len(baseline))
 		for i := range baseline {
-			if baseline[i] > 0 {
-				pairwiseGains[i] = ((baseline[i] - optimized[i]) / baseline[i]) * 100
-			}
+			pairwiseGains[i] = Gain(baseline[i], optimized[i], opts.Direction)
 		}
 		pairStats := Calculate(pairwiseGains)
 		comp.GainP10 = pairStats.P10
 		comp.GainP90 = pairStats.P90
 	} else {
-		// Estimate from distribution overlap
-		comp.GainP10 = gainPercent - (baselineStats.CV + optimizedStats.CV) / 2
-		comp.GainP90 = gainPercent + (baselineStats.CV + optimizedStats.CV) / 2
+		// Without pairs, read the spread from the bootstrap distribution
+		comp.GainP10 = percentile(boot.Dist, 10)
+		comp.GainP90 = percentile(boot.Dist, 90)
 	}
 
-	// Calculate overlap between distributions
-	comp.Overlap = calculateOverlap(baseline, optimized)
+	comp.compareRanks(baseline, optimized, paired, opts)
+	return comp
+}
 
-	// Determine if result is conclusive
-	// Conclusive if: low CV, low overlap, consistent direction
-	avgCV := (baselineStats.CV + optimizedStats.CV) / 2
-	comp.Conclusive = avgCV < 15 && comp.Overlap < 0.3 && 
-		((comp.GainP10 > 0 && comp.GainP90 > 0) || (comp.GainP10 < 0 && comp.GainP90 < 0))
+// compareRanks fills in the overlap between the distributions, the rank
+// tests and effect sizes, then whether the comparison is conclusive.
+func (c *Comparison) compareRanks(baseline, optimized []float64, paired bool, opts Options) {
+	c.Overlap = calculateOverlap(baseline, optimized)
+
+	// Rank tests and effect sizes
+	c.MannWhitneyU, c.MannWhitneyP = MannWhitney(baseline, optimized)
+	c.Test, c.PValue = TestMannWhitney, c.MannWhitneyP
+	if paired {
+		c.WilcoxonW, c.WilcoxonP = Wilcoxon(baseline, optimized)
+		c.Test, c.PValue = TestWilcoxon, c.WilcoxonP
+	}
+	c.CliffsDelta = CliffsDelta(baseline, optimized)
+	c.HodgesLehmann = HodgesLehmann(baseline, optimized, paired)
 
-	return comp
+	c.Conclusive = c.conclusive(c.PValue, opts)
+}
+
+// conclusive reports whether the comparison is significant at p, has a
+// non-negligible effect and a gain CI that excludes zero.
+func (c Comparison) conclusive(p float64, opts Options) bool {
+	return p < opts.Alpha &&
+		math.Abs(c.CliffsDelta) >= opts.MinEffect &&
+		((c.GainCILow > 0 && c.GainCIHigh > 0) || (c.GainCILow < 0 && c.GainCIHigh < 0))
+}
+
+// Gain returns the improvement of optimized over baseline in percent.
+// A positive value always means "better", whatever the metric direction.
+// A percentage of a baseline <= 0 means nothing, and is returned as 0:
+// Compare marks such comparisons GainUndefined instead.
+func Gain(baseline, optimized float64, dir Direction) float64 {
+	if baseline <= 0 {
+		return 0
+	}
+	diff := baseline - optimized
+	if dir == HigherIsBetter {
+		diff = optimized - baseline
+	}
+	return diff / baseline * 100
 }
 
 func percentile(sorted []float64, p float64) float64 {
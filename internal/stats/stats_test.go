@@ -0,0 +1,63 @@
+package stats
+
+import "testing"
+
+func TestGain(t *testing.T) {
+	tests := []struct {
+		name                string
+		baseline, optimized float64
+		dir                 Direction
+		want                float64
+	}{
+		{name: "faster", baseline: 200, optimized: 150, dir: LowerIsBetter, want: 25},
+		{name: "slower", baseline: 100, optimized: 150, dir: LowerIsBetter, want: -50},
+		{name: "higher", baseline: 100, optimized: 150, dir: HigherIsBetter, want: 50},
+		// A percentage of zero or a negative baseline means nothing
+		{name: "zero baseline", baseline: 0, optimized: 3, dir: LowerIsBetter, want: 0},
+		{name: "zero baseline higher", baseline: 0, optimized: 3, dir: HigherIsBetter, want: 0},
+		{name: "negative baseline", baseline: -2, optimized: -5, dir: LowerIsBetter, want: 0},
+		{name: "all zero", baseline: 0, optimized: 0, dir: HigherIsBetter, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Gain(tt.baseline, tt.optimized, tt.dir); !near(got, tt.want, 1e-12) {
+				t.Errorf("Gain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareMissing(t *testing.T) {
+	for _, tt := range []struct {
+		name                string
+		baseline, optimized []float64
+	}{
+		{name: "no baseline", optimized: []float64{1, 2}},
+		{name: "no optimized", baseline: []float64{1, 2}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Compare(tt.baseline, tt.optimized, Options{Seed: 1})
+			if !c.Missing || c.HasGain() || c.Conclusive {
+				t.Errorf("Compare() = missing %v, has gain %v, conclusive %v, want missing without gain", c.Missing, c.HasGain(), c.Conclusive)
+			}
+		})
+	}
+	if c := Compare([]float64{1, 2}, []float64{1, 2}, Options{Seed: 1}); c.Missing {
+		t.Error("Compare() of two samples is missing")
+	}
+}
+
+func TestCompareGainUndefined(t *testing.T) {
+	baseline := []float64{0, 1, 2, 3, 4, 5, 6, 7}
+	optimized := []float64{20, 21, 22, 23, 24, 25, 26, 27}
+	c := Compare(baseline, optimized, Options{Direction: HigherIsBetter, Confidence: 0.95, Seed: 1})
+	if !c.GainUndefined || c.HasGain() || c.Conclusive {
+		t.Errorf("Compare() = undefined %v, has gain %v, conclusive %v, want undefined without gain", c.GainUndefined, c.HasGain(), c.Conclusive)
+	}
+	if c.GainPercent != 0 || c.GainCILow != 0 || c.GainCIHigh != 0 {
+		t.Errorf("Compare() gain = %v [%v, %v], want none", c.GainPercent, c.GainCILow, c.GainCIHigh)
+	}
+	if c.MannWhitneyP >= 0.05 {
+		t.Errorf("Compare() p = %v, want the rank test still run", c.MannWhitneyP)
+	}
+}
@@ -0,0 +1,79 @@
+package verify
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Limits of a diff excerpt.
+const (
+	contextLines = 3
+	shownLines   = 5
+	maxLineLen   = 200
+	maxExcerpt   = 2000
+)
+
+// Diff returns an excerpt of how b differs from a: the lines between their
+// common beginning and common end, those of a prefixed with "-" and those of
+// b with "+", with a few lines of context. It is not a full diff: changes
+// far apart show as one block, which is enough to locate the mismatch.
+func Diff(a, b []byte, nameA, nameB string) string {
+	linesA := split(a)
+	linesB := split(b)
+	first := 0
+	for first < len(linesA) && first < len(linesB) && linesA[first] == linesB[first] {
+		first++
+	}
+	endA, endB := len(linesA), len(linesB)
+	for endA > first && endB > first && linesA[endA-1] == linesB[endB-1] {
+		endA--
+		endB--
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n@@ line %d @@\n", nameA, nameB, first+1)
+	for i := max(0, first-contextLines); i < first; i++ {
+		sb.WriteString("  " + line(linesA[i]))
+	}
+	for _, side := range []struct {
+		prefix string
+		lines  []string
+	}{{"- ", linesA[first:endA]}, {"+ ", linesB[first:endB]}} {
+		for i, l := range side.lines {
+			if i == shownLines {
+				fmt.Fprintf(&sb, "%s... (%d more lines)\n", side.prefix, len(side.lines)-i)
+				break
+			}
+			sb.WriteString(side.prefix + line(l))
+		}
+	}
+	for i := endA; i < min(len(linesA), endA+contextLines); i++ {
+		sb.WriteString("  " + line(linesA[i]))
+	}
+	return sb.String()
+}
+
+// split cuts output into lines, keeping their newlines so that a missing
+// final newline counts as a difference.
+func split(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// line formats one line of an excerpt, shortened and made visible when it
+// is missing its newline.
+func line(s string) string {
+	if !strings.HasSuffix(s, "\n") {
+		s += " (no newline at end)\n"
+	}
+	if len(s) > maxLineLen {
+		s = s[:maxLineLen] + "...\n"
+	}
+	return s
+}
@@ -0,0 +1,253 @@
+// Package verify checks that every scenario produces the same output as the
+// baseline: a gain means nothing if the optimized script computes something
+// else. The output of each successful run (its stdout or a file it writes)
+// is compared with the baseline's first one, byte for byte, by SHA-256
+// digest, or with a user-supplied checker command.
+package verify
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Sources of the compared output.
+const (
+	SourceStdout = "stdout"
+	// SourceFile is followed by the path the scenarios write, "file:PATH"
+	SourceFile = "file"
+)
+
+// commandTimeout bounds one run of a checker command.
+const commandTimeout = time.Minute
+
+// Summary is the outcome of the verification, for the report.
+type Summary struct {
+	Method string `json:"method"`
+	Passed bool   `json:"passed"`
+	// Error tells why nothing could be verified, e.g. no baseline run
+	// succeeded
+	Error     string   `json:"error,omitempty"`
+	Scenarios []Result `json:"scenarios"`
+}
+
+// Result is the verification of one scenario's runs.
+type Result struct {
+	Scenario   string `json:"scenario"`
+	Checked    int    `json:"checked"`
+	Mismatches int    `json:"mismatches"`
+	// FirstMismatch is the 1-based number of the first run that differed
+	FirstMismatch int `json:"first_mismatch_run,omitempty"`
+	// Excerpt shows how it differed: a diff, the digests or the checker's
+	// output
+	Excerpt string `json:"excerpt,omitempty"`
+}
+
+// Checker compares the output of every run with the reference: the first
+// successful output of the baseline. Runs finished before the reference is
+// known are kept until it is.
+type Checker struct {
+	baseline string
+	file     string
+	command  string
+	checksum bool
+
+	reference []byte
+	refFile   string
+	err       error
+	pending   []output
+	results   map[string]*Result
+}
+
+type output struct {
+	scenario string
+	run      int
+	data     []byte
+	err      error
+}
+
+// New creates a checker of the given source ("stdout" or "file:PATH"). With
+// checksum only the digests are kept and compared, which suits large or
+// binary output; a command, when set, decides instead of the byte
+// comparison. baseline is the name of the reference scenario.
+func New(source, command string, checksum bool, baseline string) (*Checker, error) {
+	c := &Checker{baseline: baseline, command: command, checksum: checksum, results: make(map[string]*Result)}
+	kind, path, _ := strings.Cut(source, ":")
+	switch {
+	case source == SourceStdout:
+	case kind == SourceFile && path != "":
+		c.file = path
+	default:
+		return nil, fmt.Errorf("unknown verification source %q (expected %s or %s:PATH)", source, SourceStdout, SourceFile)
+	}
+	if checksum && command != "" {
+		return nil, fmt.Errorf("a checker command needs the whole output, not its checksum")
+	}
+	return c, nil
+}
+
+// Method describes how outputs are compared.
+func (c *Checker) Method() string {
+	source := SourceStdout
+	if c.file != "" {
+		source = c.file
+	}
+	switch {
+	case c.command != "":
+		return fmt.Sprintf("%s, checked by %q", source, c.command)
+	case c.checksum:
+		return source + ", SHA-256"
+	}
+	return source + ", byte for byte"
+}
+
+// File is the output file the scenarios write, or "" for stdout. It is
+// removed before each run so that a stale copy is not mistaken for output.
+func (c *Checker) File() string {
+	return c.file
+}
+
+// Check records the output of one successful run of scenario, numbered
+// from 1. readErr is set when the output file could not be read.
+func (c *Checker) Check(scenario string, run int, data []byte, readErr error) {
+	if c.checksum && readErr == nil {
+		sum := sha256.Sum256(data)
+		data = sum[:]
+	}
+	o := output{scenario: scenario, run: run, data: data, err: readErr}
+	if c.reference == nil {
+		if scenario != c.baseline || readErr != nil {
+			c.pending = append(c.pending, o)
+			return
+		}
+		if c.err = c.setReference(data); c.err != nil {
+			return
+		}
+		c.result(scenario).Checked++
+		for _, p := range c.pending {
+			c.compare(p)
+		}
+		c.pending = nil
+		return
+	}
+	c.compare(o)
+}
+
+func (c *Checker) setReference(data []byte) error {
+	if data == nil {
+		data = []byte{}
+	}
+	if c.command != "" {
+		f, err := os.CreateTemp("", "corecut-reference-*")
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := f.Write(data); err != nil {
+			os.Remove(f.Name())
+			return err
+		}
+		c.refFile = f.Name()
+	}
+	c.reference = data
+	return nil
+}
+
+func (c *Checker) compare(o output) {
+	r := c.result(o.scenario)
+	r.Checked++
+	var excerpt string
+	switch {
+	case o.err != nil:
+		excerpt = o.err.Error()
+	case c.command != "":
+		excerpt = c.runCommand(o.data)
+	case !bytes.Equal(c.reference, o.data):
+		if c.checksum {
+			excerpt = fmt.Sprintf("SHA-256 %s (baseline) ≠ %s", hex.EncodeToString(c.reference), hex.EncodeToString(o.data))
+		} else {
+			excerpt = Diff(c.reference, o.data, c.baseline, o.scenario)
+		}
+	default:
+		return
+	}
+	if excerpt == "" {
+		return
+	}
+	r.Mismatches++
+	if r.FirstMismatch == 0 {
+		r.FirstMismatch = o.run
+		r.Excerpt = excerpt
+	}
+}
+
+// runCommand runs the checker with the reference and the output as
+// arguments. It returns "" when they are equivalent (exit status 0), else
+// the checker's output.
+func (c *Checker) runCommand(data []byte) string {
+	f, err := os.CreateTemp("", "corecut-output-*")
+	if err != nil {
+		return err.Error()
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	f.Close()
+	if err != nil {
+		return err.Error()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "/bin/bash", "-c", c.command+` "$@"`, "verify", c.refFile, f.Name())
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return ""
+	}
+	excerpt := strings.TrimSpace(string(out))
+	if len(excerpt) > maxExcerpt {
+		excerpt = excerpt[:maxExcerpt] + "\n..."
+	}
+	if excerpt == "" {
+		excerpt = "checker failed: " + err.Error()
+	}
+	return excerpt
+}
+
+func (c *Checker) result(scenario string) *Result {
+	r, ok := c.results[scenario]
+	if !ok {
+		r = &Result{Scenario: scenario}
+		c.results[scenario] = r
+	}
+	return r
+}
+
+// Summary returns the verification of the given scenarios, in order.
+func (c *Checker) Summary(scenarios []string) *Summary {
+	s := &Summary{Method: c.Method(), Passed: c.reference != nil}
+	switch {
+	case c.err != nil:
+		s.Error = "failed to keep the reference output: " + c.err.Error()
+	case c.reference == nil:
+		s.Error = "no successful baseline run to compare with"
+	}
+	for _, name := range scenarios {
+		r := c.result(name)
+		s.Passed = s.Passed && r.Mismatches == 0
+		s.Scenarios = append(s.Scenarios, *r)
+	}
+	return s
+}
+
+// Close removes the checker's temporary files.
+func (c *Checker) Close() {
+	if c.refFile != "" {
+		os.Remove(c.refFile)
+	}
+}